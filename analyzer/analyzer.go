@@ -0,0 +1,100 @@
+// Package analyzer provides go/analysis Analyzer of typeconv which reports
+// type conversion errors with suggested fixes.
+//
+// It can be used with drivers like singlechecker, multichecker and gopls.
+//
+//	func main() { singlechecker.Main(analyzer.Analyzer) }
+package analyzer
+
+import (
+	"fmt"
+	"go/ast"
+
+	typeconv "github.com/haya14busa/go-typeconv"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Analyzer reports type conversion errors which typeconv can fix.
+var Analyzer = &analysis.Analyzer{
+	Name:             "typeconv",
+	Doc:              "report type conversion errors and suggest fixes with explicit type conversion",
+	URL:              "https://github.com/haya14busa/go-typeconv",
+	Run:              run,
+	RunDespiteErrors: true,
+}
+
+// lossy is the policy for lossy conversions.
+var lossy typeconv.LossyPolicy
+
+func init() {
+	Analyzer.Flags.Var(&lossy, "lossy", "policy for lossy conversions: allow, refuse or check")
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	pkg := &typeconv.Package{
+		Fset:      pass.Fset,
+		Files:     pass.Files,
+		Types:     pass.Pkg,
+		TypesInfo: pass.TypesInfo,
+		ReadFile:  pass.ReadFile,
+	}
+	rw := typeconv.NewRewriter(typeconv.Options{Lossy: lossy})
+	fixes, err := rw.PackageFixes(pkg, pass.TypeErrors)
+	if err != nil {
+		return nil, err
+	}
+	for _, fix := range fixes {
+		rule := fix.Rule
+		if fix.Safety != typeconv.Lossless {
+			rule += ", " + fix.Safety.String()
+		}
+		diag := analysis.Diagnostic{
+			Pos:     fix.Pos,
+			End:     fix.End,
+			Message: fmt.Sprintf("type conversion error: %s (%s)", fix.Original, rule),
+		}
+		if fix.Unfixable != "" {
+			diag.Message += ": " + fix.Unfixable
+			pass.Report(diag)
+			continue
+		}
+		// Suggested fixes cannot create the helper file.
+		if len(fix.Helpers) > 0 {
+			pass.Report(diag)
+			continue
+		}
+		edits := textEdits(fix.Edits)
+		if f := file(pass, fix); f != nil {
+			edits = append(edits, textEdits(typeconv.ImportEdits(f, fix.Imports))...)
+		}
+		diag.SuggestedFixes = []analysis.SuggestedFix{{
+			Message:   fmt.Sprintf("Replace with %s", fix.Replacement),
+			TextEdits: edits,
+		}}
+		pass.Report(diag)
+	}
+	return nil, nil
+}
+
+func textEdits(edits []typeconv.Edit) []analysis.TextEdit {
+	tedits := make([]analysis.TextEdit, 0, len(edits))
+	for _, e := range edits {
+		tedits = append(tedits, analysis.TextEdit{
+			Pos:     e.Pos,
+			End:     e.End,
+			NewText: []byte(e.NewText),
+		})
+	}
+	return tedits
+}
+
+// file returns the file which the fix belongs to.
+func file(pass *analysis.Pass, fix *typeconv.Fix) *ast.File {
+	for _, f := range pass.Files {
+		if f.FileStart <= fix.Pos && fix.End <= f.FileEnd {
+			return f
+		}
+	}
+	return nil
+}
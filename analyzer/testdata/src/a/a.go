@@ -0,0 +1,18 @@
+package a
+
+import "net/http"
+
+func f(x int, y float64) {
+	var _ float64 = x              // want `type conversion error: x \(int -> float64, truncating\)`
+	_ = x * y                      // want `type conversion error: x \(int -> float64, truncating\)`
+	http.DefaultClient.Timeout = x // want `type conversion error: x \(int -> time.Duration\)`
+}
+
+func h() {
+	var _ uint = int(1)  // want `type conversion error: int\(1\) \(int -> uint\)`
+	var _ uint = int(-1) // want `type conversion error: int\(-1\) \(int -> uint\): constant -1 overflows uint`
+}
+
+func g(x int) int {
+	return int64(x) // want `type conversion error: int64\(x\) \(int64 -> int\)`
+}
@@ -5,31 +5,50 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
-	"go/ast"
 	"go/format"
+	"go/types"
 	"io"
 	"io/ioutil"
+	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	typeconv "github.com/haya14busa/go-typeconv"
 
-	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/packages"
 )
 
 type option struct {
-	write  bool
-	doDiff bool
-	rules  strslice
+	write   bool
+	doDiff  bool
+	tags    string
+	passes  int
+	spread  bool
+	lossy   typeconv.LossyPolicy
+	rules   strslice
+	config  string
+	verbose bool
+	// set holds the names of flags set on the command line, which override
+	// the configuration file.
+	set map[string]bool
 }
 
 func main() {
 	opt := &option{}
 	flag.BoolVar(&opt.write, "w", false, "write result to (source) file instead of stdout")
 	flag.BoolVar(&opt.doDiff, "d", false, "display diffs instead of rewriting files")
+	flag.StringVar(&opt.tags, "tags", "", "comma-separated list of build tags to apply when loading packages")
+	flag.IntVar(&opt.passes, "passes", 1, "maximum number of passes to fix errors which previous fixes reveal")
+	flag.BoolVar(&opt.spread, "spread", false, "convert spread slice arguments of variadic functions (e.g. f(xs...)) to new slices")
+	flag.Var(&opt.lossy, "lossy", "policy for lossy conversions (e.g. float64 -> int): allow, refuse or check (wrap them with checked helper)")
 	flag.Var(&opt.rules, "r", "type conversion rules currently just for type conversion of binary expression (e.g., 'int -> uint32')")
+	flag.BoolVar(&opt.verbose, "v", false, "log type errors which are skipped or cannot be fixed")
+	flag.StringVar(&opt.config, "config", "", "configuration file (default: .gotypeconv.yaml, .gotypeconv.yml or .gotypeconv.toml found from the target directory upward)")
 	flag.Parse()
+	opt.set = make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { opt.set[f.Name] = true })
 	out := bufio.NewWriter(os.Stdout)
 	defer out.Flush()
 	if err := run(out, flag.Args(), opt); err != nil {
@@ -39,42 +58,114 @@ func main() {
 }
 
 func run(w io.Writer, args []string, opt *option) error {
-	if err := addRules(opt.rules); err != nil {
+	rw, err := loadRewriter(args, opt)
+	if err != nil {
 		return err
 	}
-	prog, typeErrs, err := typeconv.Load(loader.Config{}, args)
+	cfg := &packages.Config{}
+	if opt.tags != "" {
+		cfg.BuildFlags = []string{"-tags=" + opt.tags}
+	}
+	prog, typeErrs, err := typeconv.Load(cfg, args...)
 	if err != nil {
 		return err
 	}
-	if err := typeconv.RewriteProgam(prog, typeErrs); err != nil {
+	if opt.passes > 1 {
+		return runFixpoint(w, opt, rw, prog, typeErrs)
+	}
+	fixes, err := rw.Fixes(prog, typeErrs)
+	if err != nil {
 		return err
 	}
-	for _, pkg := range prog.InitialPackages() {
-		for _, f := range pkg.Files {
-			if err := printFile(w, opt, prog, f); err != nil {
+	fileFixes := make(map[string][]*typeconv.Fix)
+	for _, fix := range fixes {
+		if fix.Unfixable != "" {
+			fmt.Fprintf(os.Stderr, "%v: %s\n", prog.Fset.Position(fix.Pos), fix.Unfixable)
+		}
+		fileFixes[fix.Filename] = append(fileFixes[fix.Filename], fix)
+	}
+	for _, pkg := range prog.Packages {
+		for _, f := range pkg.Syntax {
+			filename := prog.Fset.File(f.Pos()).Name()
+			src, err := prog.ReadFile(filename)
+			if err != nil {
 				return err
 			}
+			res, err := typeconv.ApplyFixes(prog.Fset, src, fileFixes[filename])
+			if err != nil {
+				return fmt.Errorf("%s: %v", filename, err)
+			}
+			if err := printFile(w, opt, filename, src, res); err != nil {
+				return err
+			}
+		}
+	}
+	helperFiles, err := typeconv.HelperFiles(prog, fixes)
+	if err != nil {
+		return err
+	}
+	for filename, res := range helperFiles {
+		src, err := prog.ReadFile(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := printFile(w, opt, filename, src, res); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func printFile(w io.Writer, opt *option, prog *loader.Program, f *ast.File) error {
-	filename := prog.Fset.File(f.Pos()).Name()
-	buf := new(bytes.Buffer)
-	if err := format.Node(buf, prog.Fset, f); err != nil {
-		return err
+// runFixpoint rewrites the program repeatedly up to opt.passes times.
+func runFixpoint(w io.Writer, opt *option, rw *typeconv.Rewriter, prog *typeconv.Program, typeErrs []types.Error) error {
+	srcs := make(map[string][]byte)
+	for _, pkg := range prog.Packages {
+		for _, f := range pkg.Syntax {
+			filename := prog.Fset.File(f.Pos()).Name()
+			src, err := prog.ReadFile(filename)
+			if err != nil {
+				return err
+			}
+			srcs[filename] = src
+		}
 	}
-	res := buf.Bytes()
-	in, err := os.Open(filename)
+	fixpoint, err := rw.RewriteProgamFixpoint(prog, typeErrs, opt.passes)
 	if err != nil {
 		return err
 	}
-	defer in.Close()
-	src, err := ioutil.ReadAll(in)
-	if err != nil {
-		return err
+	for _, fix := range fixpoint.Unfixable {
+		fmt.Fprintf(os.Stderr, "%v: %s\n", prog.Fset.Position(fix.Pos), fix.Unfixable)
+	}
+	if len(fixpoint.Remaining) > 0 {
+		fmt.Fprintf(os.Stderr, "%d type errors remain after pass %d:\n", len(fixpoint.Remaining), fixpoint.Passes)
+		for _, e := range fixpoint.Remaining {
+			fmt.Fprintln(os.Stderr, e)
+		}
 	}
+	// Rewriting may add helper files.
+	var filenames []string
+	for _, pkg := range prog.Packages {
+		for _, f := range pkg.Syntax {
+			filenames = append(filenames, prog.Fset.File(f.Pos()).Name())
+		}
+	}
+	for _, filename := range filenames {
+		b, err := prog.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		res, err := format.Source(b)
+		if err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+		if err := printFile(w, opt, filename, srcs[filename], res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printFile(w io.Writer, opt *option, filename string, src, res []byte) error {
 	if !bytes.Equal(src, res) {
 		if opt.write {
 			fh, err := os.Create(filename)
@@ -99,16 +190,66 @@ func printFile(w io.Writer, opt *option, prog *loader.Program, f *ast.File) erro
 	return nil
 }
 
-func addRules(rules []string) error {
-	for _, r := range rules {
-		f := strings.Split(r, "->")
-		if len(f) != 2 {
-			return fmt.Errorf("type conversion rule must be the form 'from -> to': %v", r)
+// loadRewriter loads the configuration file and overrides it with flags.
+func loadRewriter(args []string, opt *option) (*typeconv.Rewriter, error) {
+	filename := opt.config
+	if filename == "" {
+		var err error
+		if filename, err = typeconv.FindConfig(configDir(args)); err != nil {
+			return nil, err
 		}
-		from, to := strings.TrimSpace(f[0]), strings.TrimSpace(f[1])
-		typeconv.DefaultRule.Add(from, to)
 	}
-	return nil
+	cfg := &typeconv.Config{}
+	if filename != "" {
+		var err error
+		if cfg, err = typeconv.LoadConfig(filename); err != nil {
+			return nil, err
+		}
+	}
+	// Earlier rules have higher priority, so rules of -r flags precede the
+	// rules of the configuration.
+	var rules []string
+	for _, r := range opt.rules {
+		if _, _, err := typeconv.ParseConversion(r); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	cfg.Rules = append(rules, cfg.Rules...)
+	rw, err := cfg.Rewriter()
+	if err != nil {
+		return nil, err
+	}
+	if opt.set["spread"] {
+		rw.Options.SpreadSlice = opt.spread
+	}
+	if opt.set["lossy"] {
+		rw.Options.Lossy = opt.lossy
+	}
+	if opt.verbose {
+		rw.Logger = log.New(os.Stderr, "gotypeconv: ", 0)
+	}
+	return rw, nil
+}
+
+// configDir returns the directory to start finding the configuration file
+// from the first target (e.g. ./pkg/..., a file or a directory).
+func configDir(args []string) string {
+	if len(args) == 0 {
+		return "."
+	}
+	path := strings.TrimSuffix(args[0], "...")
+	if path == "" {
+		return "."
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "."
+	}
+	if fi.IsDir() {
+		return path
+	}
+	return filepath.Dir(path)
 }
 
 // copied and modified from $GOPATH/src/github.com/golang/go/src/cmd/gofmt/gofmt.go
@@ -2,12 +2,15 @@ package main
 
 import (
 	"bytes"
+	"go/types"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	typeconv "github.com/haya14busa/go-typeconv"
+
 	ddiff "github.com/kylelemons/godebug/diff"
 )
 
@@ -60,3 +63,52 @@ func BenchmarkRun(b *testing.B) {
 		}
 	}
 }
+
+func TestLoadRewriter_flagsOverrideConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, ".gotypeconv.yaml")
+	if err := ioutil.WriteFile(config, []byte("lossy: refuse\nspread: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		opt        *option
+		wantLossy  typeconv.LossyPolicy
+		wantSpread bool
+	}{
+		{&option{config: config}, typeconv.LossyRefuse, true},
+		{&option{config: config, lossy: typeconv.LossyAllow, set: map[string]bool{"lossy": true, "spread": true}}, typeconv.LossyAllow, false},
+		{&option{config: config, lossy: typeconv.LossyCheck, set: map[string]bool{"lossy": true}}, typeconv.LossyCheck, true},
+	}
+	for i, tt := range tests {
+		rw, err := loadRewriter(nil, tt.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rw.Options.Lossy != tt.wantLossy || rw.Options.SpreadSlice != tt.wantSpread {
+			t.Errorf("#%d: Lossy, SpreadSlice == %v, %v, want %v, %v", i, rw.Options.Lossy, rw.Options.SpreadSlice, tt.wantLossy, tt.wantSpread)
+		}
+	}
+}
+
+func TestLoadRewriter_flagRulesOverrideConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, ".gotypeconv.yaml")
+	if err := ioutil.WriteFile(config, []byte("rules:\n  - uint -> int64\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rw, err := loadRewriter(nil, &option{config: config, rules: []string{"int64 -> uint"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	flagPriority, ok := rw.Rule.Priority("", types.Typ[types.Int64], types.Typ[types.Uint])
+	if !ok {
+		t.Fatal("int64 -> uint is not added")
+	}
+	configPriority, ok := rw.Rule.Priority("", types.Typ[types.Uint], types.Typ[types.Int64])
+	if !ok {
+		t.Fatal("uint -> int64 is not added")
+	}
+	if flagPriority <= configPriority {
+		t.Errorf("priority of int64 -> uint (-r) == %d, want higher than uint -> int64 (config) %d", flagPriority, configPriority)
+	}
+}
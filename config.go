@@ -0,0 +1,270 @@
+package typeconv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFilenames are the names of configuration files in order of
+// precedence.
+var ConfigFilenames = []string{".gotypeconv.yaml", ".gotypeconv.yml", ".gotypeconv.toml"}
+
+// Config represents the configuration file of gotypeconv.
+//
+// YAML example:
+//
+//	rules:
+//	  - int -> uint32
+//	deny:
+//	  - conversion: float64 -> int
+//	    contexts: [assign, return]
+//	exclude:
+//	  - vendor
+//	  - "*_gen.go"
+//	templates:
+//	  - conversion: int -> string
+//	    template: strconv.Itoa({{x}})
+//	kinds: [vardecl, funcarg, return]
+type Config struct {
+	// Rules are type conversions ("from -> to", see Rule for type patterns)
+	// added to the default rule, used for binary expressions. Earlier rules
+	// have higher priority.
+	Rules []string `yaml:"rules" toml:"rules"`
+	// Allow and Deny are policies applied in order. Deny takes precedence
+	// over Allow.
+	Allow []PolicyConfig `yaml:"allow" toml:"allow"`
+	Deny  []PolicyConfig `yaml:"deny" toml:"deny"`
+	// Templates are conversion templates used instead of type conversions
+	// (see Template).
+	Templates []TemplateConfig `yaml:"templates" toml:"templates"`
+	// Exclude holds the file patterns or directories not to fix, relative to
+	// the directory of the configuration file. Patterns without path
+	// separators also match the names of files at any depth (e.g.
+	// *_gen.go).
+	Exclude []string `yaml:"exclude" toml:"exclude"`
+	// Kinds are the contexts to fix (e.g. vardecl, funcarg). All contexts
+	// are fixed if it's empty.
+	Kinds []string `yaml:"kinds" toml:"kinds"`
+	// Lossy is the policy for lossy conversions: allow, refuse or check.
+	Lossy string `yaml:"lossy" toml:"lossy"`
+	// Spread enables conversions of spread slice arguments.
+	Spread bool `yaml:"spread" toml:"spread"`
+
+	// Dir is the directory of the configuration file.
+	Dir string `yaml:"-" toml:"-"`
+}
+
+// PolicyConfig represents an allowed or denied conversion.
+type PolicyConfig struct {
+	// Conversion is the form of "from -> to".
+	Conversion string `yaml:"conversion" toml:"conversion"`
+	// Contexts are the contexts where the policy applies. It applies in any
+	// context if it's empty.
+	Contexts []string `yaml:"contexts" toml:"contexts"`
+	// Package is the import path of the package where the policy applies.
+	// It applies in any package if it's empty.
+	Package string `yaml:"package" toml:"package"`
+}
+
+// TemplateConfig represents a conversion template.
+type TemplateConfig struct {
+	// Conversion is the form of "from -> to".
+	Conversion string `yaml:"conversion" toml:"conversion"`
+	// Template is the conversion template (e.g. strconv.Itoa({{x}})).
+	Template string `yaml:"template" toml:"template"`
+	// Package is the import path of the package where the template applies.
+	// It applies in any package if it's empty.
+	Package string `yaml:"package" toml:"package"`
+}
+
+// FindConfig finds a configuration file in dir or its parent directories. It
+// returns an empty string if not found.
+func FindConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		for _, name := range ConfigFilenames {
+			filename := filepath.Join(dir, name)
+			if fi, err := os.Stat(filename); err == nil && !fi.IsDir() {
+				return filename, nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadConfig loads and validates the configuration file. The format is
+// determined by the extension (.yaml, .yml or .toml).
+func LoadConfig(filename string) (*Config, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	switch ext := filepath.Ext(filename); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(b), cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		if keys := md.Undecoded(); len(keys) > 0 {
+			return nil, fmt.Errorf("%s: unknown field %q", filename, keys[0].String())
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown configuration format %q (.yaml, .yml or .toml)", filename, ext)
+	}
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return nil, err
+	}
+	cfg.Dir = dir
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) validate() error {
+	for i, r := range cfg.Rules {
+		if _, _, err := ParseConversion(r); err != nil {
+			return fmt.Errorf("rules[%d]: %v", i, err)
+		}
+	}
+	for _, policies := range []struct {
+		name string
+		list []PolicyConfig
+	}{{"allow", cfg.Allow}, {"deny", cfg.Deny}} {
+		name := policies.name
+		for i, p := range policies.list {
+			if _, _, err := ParseConversion(p.Conversion); err != nil {
+				return fmt.Errorf("%s[%d]: %v", name, i, err)
+			}
+			if _, err := parseContexts(p.Contexts); err != nil {
+				return fmt.Errorf("%s[%d]: %v", name, i, err)
+			}
+		}
+	}
+	for i, t := range cfg.Templates {
+		if _, _, err := ParseConversion(t.Conversion); err != nil {
+			return fmt.Errorf("templates[%d]: %v", i, err)
+		}
+		if _, err := ParseTemplate(t.Template); err != nil {
+			return fmt.Errorf("templates[%d]: %v", i, err)
+		}
+	}
+	for i, pattern := range cfg.Exclude {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("exclude[%d]: invalid pattern %q: %v", i, pattern, err)
+		}
+	}
+	if _, err := parseContexts(cfg.Kinds); err != nil {
+		return fmt.Errorf("kinds: %v", err)
+	}
+	if cfg.Lossy != "" {
+		var lossy LossyPolicy
+		if err := lossy.Set(cfg.Lossy); err != nil {
+			return fmt.Errorf("lossy: %v", err)
+		}
+	}
+	return nil
+}
+
+// Rewriter returns a new Rewriter which the configuration represents. Its
+// rule is based on the default rule (see NewDefaultRule).
+func (cfg *Config) Rewriter() (*Rewriter, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	rule := NewDefaultRule()
+	for _, r := range cfg.Rules {
+		from, to, _ := ParseConversion(r)
+		rule.Add(from, to)
+	}
+	for _, p := range cfg.Allow {
+		p.apply(rule, true)
+	}
+	for _, p := range cfg.Deny {
+		p.apply(rule, false)
+	}
+	for _, t := range cfg.Templates {
+		from, to, _ := ParseConversion(t.Conversion)
+		tmpl, _ := ParseTemplate(t.Template)
+		r := rule
+		if t.Package != "" {
+			r = rule.Package(t.Package)
+		}
+		r.AddTemplate(from, to, tmpl)
+	}
+	opts := Options{SpreadSlice: cfg.Spread}
+	opts.Contexts, _ = parseContexts(cfg.Kinds)
+	if cfg.Lossy != "" {
+		opts.Lossy.Set(cfg.Lossy)
+	}
+	for _, pattern := range cfg.Exclude {
+		if !strings.ContainsAny(pattern, "/"+string(filepath.Separator)) {
+			// File name patterns match at any depth.
+			opts.Exclude = append(opts.Exclude, pattern)
+		}
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(cfg.Dir, pattern)
+		}
+		opts.Exclude = append(opts.Exclude, pattern)
+	}
+	return &Rewriter{Rule: rule, Options: opts}, nil
+}
+
+func (p *PolicyConfig) apply(rule *Rule, allow bool) {
+	from, to, _ := ParseConversion(p.Conversion)
+	ctxs, _ := parseContexts(p.Contexts)
+	if p.Package != "" {
+		rule = rule.Package(p.Package)
+	}
+	if allow {
+		rule.Allow(from, to, ctxs...)
+	} else {
+		rule.Deny(from, to, ctxs...)
+	}
+}
+
+func parseContexts(names []string) ([]Context, error) {
+	var ctxs []Context
+	for _, name := range names {
+		ctx, err := parseContext(name)
+		if err != nil {
+			return nil, err
+		}
+		ctxs = append(ctxs, ctx)
+	}
+	return ctxs, nil
+}
+
+func parseContext(name string) (Context, error) {
+	names := make([]string, 0, len(Contexts))
+	for _, ctx := range Contexts {
+		if string(ctx) == name {
+			return ctx, nil
+		}
+		names = append(names, string(ctx))
+	}
+	return "", fmt.Errorf("unknown context %q (%s)", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,140 @@
+package typeconv
+
+import (
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindConfig(t *testing.T) {
+	want, err := filepath.Abs("testdata/config/yaml/.gotypeconv.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"testdata/config/yaml", "testdata/config/yaml/sub"} {
+		got, err := FindConfig(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("FindConfig(%q) == %q, want %q", dir, got, want)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	for _, filename := range []string{"testdata/config/yaml/.gotypeconv.yaml", "testdata/config/toml/.gotypeconv.toml"} {
+		cfg, err := LoadConfig(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw, err := cfg.Rewriter()
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := rw.Options
+		if want := []Context{ContextVarDecl, ContextAssign, ContextReturn}; !reflect.DeepEqual(opts.Contexts, want) {
+			t.Errorf("%s: Contexts == %v, want %v", filename, opts.Contexts, want)
+		}
+		if opts.Lossy != LossyRefuse {
+			t.Errorf("%s: Lossy == %v, want %v", filename, opts.Lossy, LossyRefuse)
+		}
+		if _, ok := rw.Rule.Priority("", types.Typ[types.Int], types.Typ[types.Uint32]); !ok {
+			t.Errorf("%s: rule int -> uint32 is not added", filename)
+		}
+		if _, ok := rw.Rule.Priority("", types.Typ[types.Int], types.Typ[types.Int64]); !ok {
+			t.Errorf("%s: default rules are not added", filename)
+		}
+		if rw.Rule.Allowed("", ContextAssign, types.Typ[types.Float64], types.Typ[types.Int]) {
+			t.Errorf("%s: float64 -> int should be denied in assign", filename)
+		}
+		if !rw.Rule.Allowed("", ContextVarDecl, types.Typ[types.Float64], types.Typ[types.Int]) {
+			t.Errorf("%s: float64 -> int should be allowed in vardecl", filename)
+		}
+		if !rw.Rule.Package("example.com/p").Allowed("", ContextAssign, types.Typ[types.Int], types.Typ[types.Uint]) {
+			t.Errorf("%s: int -> uint should be allowed in example.com/p", filename)
+		}
+		if tmpl, ok := rw.Rule.Template("", types.Typ[types.Int], types.Typ[types.String]); !ok || tmpl.String() != "strconv.Itoa({{x}})" {
+			t.Errorf("%s: Template(int, string) == %v, %v", filename, tmpl, ok)
+		}
+		for _, f := range []string{"x_gen.go", "sub/dir/x_gen.go", "vendor/a/a.go"} {
+			if !opts.excluded(filepath.Join(cfg.Dir, f)) {
+				t.Errorf("%s: %s should be excluded", filename, f)
+			}
+		}
+		for _, f := range []string{"a.go", "sub/x_gen_test.go", "vendors/a.go"} {
+			if opts.excluded(filepath.Join(cfg.Dir, f)) {
+				t.Errorf("%s: %s should not be excluded", filename, f)
+			}
+		}
+	}
+}
+
+func TestLoadConfig_invalid(t *testing.T) {
+	tests := []struct {
+		filename string
+		src      string
+		want     string
+	}{
+		{".gotypeconv.yaml", "rules: [int]", `rules[0]: type conversion must be the form 'from -> to': "int"`},
+		{".gotypeconv.yaml", "deny: [{conversion: int -> uint, contexts: [asign]}]", `deny[0]: unknown context "asign" (vardecl, funcarg, assign, binary, return, compositelit, send, shift, comparison, unary, index)`},
+		{".gotypeconv.yaml", "kinds: [foo]", `kinds: unknown context "foo"`},
+		{".gotypeconv.yaml", "lossy: maybe", `lossy: unknown lossy conversion policy: "maybe"`},
+		{".gotypeconv.yaml", "exclude: ['[']", `exclude[0]: invalid pattern "["`},
+		{".gotypeconv.yaml", "rule: [int -> uint]", "field rule not found"},
+		{".gotypeconv.yaml", "templates: [{conversion: int -> string, template: strconv.Itoa(x)}]", `templates[0]: conversion template must contain {{x}} exactly once`},
+		{".gotypeconv.toml", `allow = [{conversion = "int ->"}]`, `allow[0]: type conversion must be the form 'from -> to': "int ->"`},
+		{".gotypeconv.toml", `rulez = []`, `unknown field "rulez"`},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		filename := filepath.Join(dir, tt.filename)
+		if err := os.WriteFile(filename, []byte(tt.src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadConfig(filename)
+		if err == nil {
+			t.Errorf("LoadConfig(%q) succeeded, want error", tt.src)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), filename+": ") || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("LoadConfig(%q) == %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestFixes_config(t *testing.T) {
+	prog, typeErrs, err := Load(nil, "testdata/tour.input.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{Kinds: []string{"vardecl"}}
+	rw, err := cfg.Rewriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixes, err := rw.Fixes(prog, typeErrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixes) != 1 || fixes[0].Replacement != "uint(f)" {
+		t.Errorf("only var declaration should be fixed: %v", fixes)
+	}
+	dir, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = &Config{Exclude: []string{"*.input.go"}, Dir: dir}
+	if rw, err = cfg.Rewriter(); err != nil {
+		t.Fatal(err)
+	}
+	if fixes, err = rw.Fixes(prog, typeErrs); err != nil {
+		t.Fatal(err)
+	}
+	if len(fixes) != 0 {
+		t.Errorf("excluded file should not be fixed: %v", fixes)
+	}
+}
@@ -0,0 +1,134 @@
+package typeconv
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"math"
+	"strconv"
+)
+
+// constFix returns the fix which converts constant expression x of value v
+// from type "from" to type "to". It reports false if the conversion isn't
+// a numeric conversion.
+//
+// Constants consisting of literals are re-emitted as untyped literals (e.g.
+// int(1) -> 1). Other constants are wrapped with conversion, which is
+// checked by the compiler. If v overflows "to", it returns the fix without
+// edits whose Unfixable describes the reason.
+func (c *fileCtx) constFix(terr TypeError, x ast.Expr, v constant.Value, from, to types.Type) (*Fix, bool) {
+	b, ok := to.Underlying().(*types.Basic)
+	if !ok || b.Info()&(types.IsInteger|types.IsFloat) == 0 || !isNumberValue(v) {
+		return nil, false
+	}
+	if !c.allowed(from, to) {
+		return nil, true
+	}
+	lit, err := constantLiteral(v, b)
+	if err != nil {
+		fix := c.newFix(terr, x, c.ruleString(from, to), nil)
+		if fix != nil {
+			fix.Unfixable = fmt.Sprintf("constant %s %v", constantString(v), err)
+		}
+		return fix, true
+	}
+	if isLiteral(x, c.info) {
+		if bl, ok := unconvert(x, c.info).(*ast.BasicLit); ok && (bl.Kind == token.INT || (bl.Kind == token.FLOAT && b.Info()&types.IsFloat != 0)) {
+			// Keep the literal as written (e.g. 0x7f).
+			lit = bl.Value
+		}
+		return c.newFix(terr, x, c.ruleString(from, to), []Edit{
+			{Pos: x.Pos(), End: x.End(), NewText: lit},
+		}), true
+	}
+	return c.newFix(terr, x, c.ruleString(from, to), []Edit{
+		{Pos: x.Pos(), End: x.Pos(), NewText: c.convertFun(to) + "("},
+		{Pos: x.End(), End: x.End(), NewText: ")"},
+	}), true
+}
+
+// constantString returns the string of numeric constant v. Floats are
+// written in decimal (e.g. 1.5 rather than 3/2).
+func constantString(v constant.Value) string {
+	if v.Kind() == constant.Float {
+		return v.String()
+	}
+	return v.ExactString()
+}
+
+func isNumberValue(v constant.Value) bool {
+	switch v.Kind() {
+	case constant.Int, constant.Float:
+		return true
+	}
+	return false
+}
+
+// isLiteral reports whether x consists of only literals, operators and
+// conversions.
+func isLiteral(x ast.Expr, info *types.Info) bool {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		return true
+	case *ast.ParenExpr:
+		return isLiteral(x.X, info)
+	case *ast.UnaryExpr:
+		return isLiteral(x.X, info)
+	case *ast.BinaryExpr:
+		return isLiteral(x.X, info) && isLiteral(x.Y, info)
+	case *ast.CallExpr:
+		tv, ok := info.Types[x.Fun]
+		return ok && tv.IsType() && len(x.Args) == 1 && isLiteral(x.Args[0], info)
+	}
+	return false
+}
+
+// unconvert returns x without enclosing conversions and parentheses.
+func unconvert(x ast.Expr, info *types.Info) ast.Expr {
+	for {
+		switch e := x.(type) {
+		case *ast.ParenExpr:
+			x = e.X
+		case *ast.CallExpr:
+			tv, ok := info.Types[e.Fun]
+			if !ok || !tv.IsType() || len(e.Args) != 1 {
+				return x
+			}
+			x = e.Args[0]
+		default:
+			return x
+		}
+	}
+}
+
+// constantLiteral returns the literal of constant v as a value of numeric
+// type t. It returns an error if v isn't representable in t. The size of
+// int, uint and uintptr is assumed to be 64 bits.
+func constantLiteral(v constant.Value, t *types.Basic) (string, error) {
+	if t.Info()&types.IsFloat != 0 {
+		f, _ := constant.Float64Val(constant.ToFloat(v))
+		if math.IsInf(f, 0) || (t.Kind() == types.Float32 && math.Abs(f) > math.MaxFloat32) {
+			return "", fmt.Errorf("overflows %s", t)
+		}
+		if v.Kind() == constant.Int {
+			return v.ExactString(), nil
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	}
+	i := constant.ToInt(v)
+	if i.Kind() != constant.Int {
+		return "", fmt.Errorf("truncated to %s", t)
+	}
+	bits := uint(basicBits(t, 64))
+	min, max := constant.MakeInt64(0), constant.Shift(constant.MakeInt64(1), token.SHL, bits)
+	if t.Info()&types.IsUnsigned == 0 {
+		max = constant.Shift(constant.MakeInt64(1), token.SHL, bits-1)
+		min = constant.UnaryOp(token.SUB, max, 0)
+	}
+	if constant.Compare(i, token.LSS, min) || constant.Compare(i, token.GEQ, max) {
+		return "", fmt.Errorf("overflows %s", t)
+	}
+	return i.ExactString(), nil
+}
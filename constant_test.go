@@ -0,0 +1,45 @@
+package typeconv
+
+import (
+	"go/constant"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+func TestConstantLiteral(t *testing.T) {
+	tests := []struct {
+		v       constant.Value
+		kind    types.BasicKind
+		want    string
+		wantErr string
+	}{
+		{constant.MakeInt64(1), types.Uint, "1", ""},
+		{constant.MakeInt64(-128), types.Int8, "-128", ""},
+		{constant.MakeInt64(255), types.Uint8, "255", ""},
+		{constant.MakeInt64(256), types.Uint8, "", "overflows uint8"},
+		{constant.MakeInt64(-129), types.Int8, "", "overflows int8"},
+		{constant.MakeInt64(-1), types.Uint, "", "overflows uint"},
+		{constant.MakeFloat64(2), types.Int, "2", ""},
+		{constant.MakeFloat64(1.5), types.Int, "", "truncated to int"},
+		{constant.MakeFloat64(1.5), types.Float32, "1.5", ""},
+		{constant.MakeInt64(3), types.Float64, "3", ""},
+		{constant.MakeFromLiteral("1e300", token.FLOAT, 0), types.Float32, "", "overflows float32"},
+	}
+	for _, tt := range tests {
+		got, err := constantLiteral(tt.v, types.Typ[tt.kind])
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("constantLiteral(%v, %v): got error %v, want %q", tt.v, types.Typ[tt.kind], err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("constantLiteral(%v, %v): unexpected error %v", tt.v, types.Typ[tt.kind], err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("constantLiteral(%v, %v) == %q, want %q", tt.v, types.Typ[tt.kind], got, tt.want)
+		}
+	}
+}
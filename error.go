@@ -1,20 +1,26 @@
 package typeconv
 
 import (
+	"go/ast"
+	"go/token"
 	"go/types"
-	"regexp"
+	"reflect"
+
+	"golang.org/x/tools/go/ast/astutil"
 )
 
 type typErr int
 
 const (
 	// cannot use x (variable of type int) as uint value in variable declaration
+	// cannot use -1 (untyped int constant) as uint value in variable declaration (overflows)
 	TypeErrVarDecl typErr = iota
 
 	// cannot use x (variable of type int) as float64 value in argument to funcarg
 	TypeErrFuncArg
 
 	// cannot use y (variable of type int) as float64 value in assignment
+	// cannot use y (variable of type int) as float64 value in multiple assignment
 	TypeErrAssign
 
 	// invalid operation: mismatched types int and float64
@@ -22,16 +28,56 @@ const (
 
 	// cannot use x (variable of type int) as float64 value in return statement
 	TypeErrReturn
+
+	// cannot use x (variable of type int) as int64 value in struct literal
+	// cannot use x (variable of type int) as int64 value in array or slice literal
+	// cannot use x (variable of type int) as int64 value in map literal
+	TypeErrCompositeLit
+
+	// cannot use x (variable of type int) as int64 value in send
+	TypeErrSend
+
+	// cannot use f() (value of type int) as int64 value in argument to g
+	// cannot use 1st function result (value of type int) as int64 value in return statement
+	// cannot use 1st function result (value of type int) as int64 value in multiple assignment
+	TypeErrMultiValue
+
+	// invalid operation: shifted operand 1.5 (untyped float constant) must be integer
+	// invalid operation: shifted operand f (variable of type float64) must be integer
+	TypeErrShiftOperand
+
+	// invalid operation: shift count f (variable of type float64) must be integer
+	// invalid operation: negative shift count -1 (untyped int constant)
+	// invalid operation: signed shift count s (variable of type int) requires go1.13 or later
+	// 1.5 (untyped float constant) truncated to uint
+	TypeErrShiftCount
+
+	// invalid operation: x == y (mismatched types MyInt and int)
+	TypeErrComparison
+
+	// -c (constant -1 of type uint) overflows uint
+	TypeErrUnary
+
+	// invalid argument: index f (variable of type float64) must be integer
+	// 1.5 (untyped float constant) truncated to int
+	// cannot use x (variable of type int) as int64 value in map index
+	TypeErrIndex
+
+	// cannot use t (variable of struct type T) as I value in argument to f: T does not implement I (method M has pointer receiver)
+	TypeErrInterface
 )
 
 // TypeError represents type error.
 type TypeError interface {
+	// Node returns the offending expression.
+	Node() ast.Expr
 	typ() typErr
 }
 
 // ErrVarDecl represents type error of variable declaration.
 //
 // Example:
+//
 //	var x, y int = 3, 4
 //	var _ float64 = x*x + y*y
 //	var _ uint = x
@@ -39,13 +85,21 @@ type TypeError interface {
 //	var _ uint = int(1) + int(1)
 //
 // Error:
+//
 //	cannot use x * x + y * y (value of type int) as float64 value in variable declaration
 //	cannot use x (variable of type int) as uint value in variable declaration
 //	cannot use int(1) (constant 1 of type int) as uint value in variable declaration
 //	cannot use int(1) + int(1) (constant 2 of type int) as uint value in variable declaration
 type ErrVarDecl struct {
-	NameType  string
-	ValueType string
+	NameType  types.Type
+	ValueType types.Type
+	Value     ast.Expr
+	Spec      *ast.ValueSpec
+}
+
+// Node returns the value of variable declaration.
+func (e *ErrVarDecl) Node() ast.Expr {
+	return e.Value
 }
 
 func (*ErrVarDecl) typ() typErr {
@@ -54,8 +108,18 @@ func (*ErrVarDecl) typ() typErr {
 
 // ErrFuncArg represents type error at function arguments.
 type ErrFuncArg struct {
-	ParamType string // https://golang.org/pkg/go/ast/#FuncType
-	ArgType   string // https://golang.org/pkg/go/ast/#CallExpr
+	ParamType types.Type // https://golang.org/pkg/go/ast/#FuncType
+	ArgType   types.Type // https://golang.org/pkg/go/ast/#CallExpr
+	Arg       ast.Expr
+	Call      *ast.CallExpr
+	// Stmt is the statement which contains Call. It's nil if the statement
+	// doesn't belong to statement list.
+	Stmt ast.Stmt
+}
+
+// Node returns the argument.
+func (e *ErrFuncArg) Node() ast.Expr {
+	return e.Arg
 }
 
 func (*ErrFuncArg) typ() typErr {
@@ -64,8 +128,15 @@ func (*ErrFuncArg) typ() typErr {
 
 // ErrAssign represents type error at (re) assignments.
 type ErrAssign struct {
-	LeftType  string
-	RightType string
+	LeftType  types.Type
+	RightType types.Type
+	Right     ast.Expr
+	Stmt      *ast.AssignStmt
+}
+
+// Node returns the right hand side expression.
+func (e *ErrAssign) Node() ast.Expr {
+	return e.Right
 }
 
 func (*ErrAssign) typ() typErr {
@@ -74,8 +145,18 @@ func (*ErrAssign) typ() typErr {
 
 // ErrMismatched represents mismatched type error.
 type ErrMismatched struct {
-	LeftType  string
-	RightType string
+	LeftType  types.Type
+	RightType types.Type
+	// WantType is the type which the context of the expression expects
+	// (e.g. the type of variable in var x float64 = a + b). It's nil if the
+	// context doesn't expect a specific type or the operator is comparison.
+	WantType types.Type
+	Expr     *ast.BinaryExpr
+}
+
+// Node returns the binary expression.
+func (e *ErrMismatched) Node() ast.Expr {
+	return e.Expr
 }
 
 func (*ErrMismatched) typ() typErr {
@@ -84,127 +165,1011 @@ func (*ErrMismatched) typ() typErr {
 
 // ErrReturn represents type error at return statement.
 type ErrReturn struct {
-	WantType string
-	GotType  string
+	WantType types.Type
+	GotType  types.Type
+	Result   ast.Expr
+	Stmt     *ast.ReturnStmt
+}
+
+// Node returns the result expression.
+func (e *ErrReturn) Node() ast.Expr {
+	return e.Result
 }
 
 func (*ErrReturn) typ() typErr {
 	return TypeErrReturn
 }
 
-var regexps = [...]*regexp.Regexp{
-	TypeErrVarDecl:    regexp.MustCompile(`\((constant .+|variable|value) of type (?P<got>.+)\) as (?P<want>.+) value in variable declaration$`),
-	TypeErrFuncArg:    regexp.MustCompile(`\((constant .+|variable|value) of type (?P<got>.+)\) as (?P<want>.+) value in argument to .*$`),
-	TypeErrAssign:     regexp.MustCompile(`\((constant .+|variable|value) of type (?P<got>.+)\) as (?P<want>.+) value in assignment$`),
-	TypeErrMismatched: regexp.MustCompile(`mismatched types (?P<left>.+) and (?P<right>.+)$`),
-	TypeErrReturn:     regexp.MustCompile(`\((constant .+|variable|value) of type (?P<got>.+)\) as (?P<want>.+) value in return statement$`),
+// ErrCompositeLit represents type error at an element of composite literal.
+// The element is a struct field value, an array or slice element, or a map
+// key or value.
+//
+// Example:
+//
+//	var x int
+//	_ = T{Field: x}
+//	_ = []int64{x}
+//	_ = map[int64]int64{x: x}
+type ErrCompositeLit struct {
+	WantType types.Type
+	GotType  types.Type
+	Elem     ast.Expr
+	Lit      *ast.CompositeLit
 }
 
-// NewTypeErr creates TypeError from types.Error.
-func NewTypeErr(err types.Error) TypeError {
-	for i, re := range regexps {
-		ms := re.FindStringSubmatch(err.Msg)
-		if len(ms) == 0 {
-			continue
+// Node returns the element expression.
+func (e *ErrCompositeLit) Node() ast.Expr {
+	return e.Elem
+}
+
+func (*ErrCompositeLit) typ() typErr {
+	return TypeErrCompositeLit
+}
+
+// ErrSend represents type error at channel send statement.
+//
+// Example:
+//
+//	var ch chan int64
+//	var x int
+//	ch <- x
+type ErrSend struct {
+	ElemType  types.Type
+	ValueType types.Type
+	Value     ast.Expr
+	Stmt      *ast.SendStmt
+}
+
+// Node returns the sent value.
+func (e *ErrSend) Node() ast.Expr {
+	return e.Value
+}
+
+func (*ErrSend) typ() typErr {
+	return TypeErrSend
+}
+
+// ErrMultiValue represents type error of multi-value function call used as
+// arguments, results of return statement or right hand side of assignment
+// and variable declaration. It cannot be fixed by wrapping the call.
+//
+// Example:
+//
+//	func f() (int, error)
+//	func g(x int64, err error)
+//
+//	g(f())
+//	var x int64
+//	var err error
+//	x, err = f()
+//	return f() // in func() (int64, error)
+type ErrMultiValue struct {
+	// WantTypes holds the destination types of results. An element is nil
+	// if its destination is blank identifier.
+	WantTypes []types.Type
+	GotTypes  *types.Tuple
+	Call      *ast.CallExpr
+	// Stmt is the statement which contains Call. It's nil if the statement
+	// doesn't belong to statement list (e.g. package level declarations).
+	Stmt ast.Stmt
+	// Context is the context where the results are used.
+	Context Context
+}
+
+// Node returns the function call.
+func (e *ErrMultiValue) Node() ast.Expr {
+	return e.Call
+}
+
+func (*ErrMultiValue) typ() typErr {
+	return TypeErrMultiValue
+}
+
+// ErrShiftOperand represents type error of shifted operand which is not an
+// integer.
+//
+// Example:
+//
+//	var f float64
+//	_ = f << 2
+//	_ = 1.5 << n
+type ErrShiftOperand struct {
+	OperandType types.Type
+	// WantType is the integer type which the operand is converted to. It's
+	// the type which the context of the shift expects if it's an integer
+	// type, or int otherwise.
+	WantType types.Type
+	Operand  ast.Expr
+	Expr     *ast.BinaryExpr
+}
+
+// Node returns the shifted operand.
+func (e *ErrShiftOperand) Node() ast.Expr {
+	return e.Operand
+}
+
+func (*ErrShiftOperand) typ() typErr {
+	return TypeErrShiftOperand
+}
+
+// ErrShiftCount represents type error of shift count which is not an
+// unsigned integer or a non-negative constant. Signed shift counts are
+// errors before Go 1.13.
+//
+// Example:
+//
+//	var f float64
+//	_ = x << f
+//	_ = x << -1
+//	_ = x << s // s is int in Go 1.12
+type ErrShiftCount struct {
+	CountType types.Type
+	// WantType is the type which the count is converted to (uint).
+	WantType types.Type
+	Count    ast.Expr
+	Expr     *ast.BinaryExpr
+}
+
+// Node returns the shift count.
+func (e *ErrShiftCount) Node() ast.Expr {
+	return e.Count
+}
+
+func (*ErrShiftCount) typ() typErr {
+	return TypeErrShiftCount
+}
+
+// ErrComparison represents mismatched types error of comparison between a
+// named type and its underlying type.
+//
+// Example:
+//
+//	type MyInt int
+//	var a MyInt
+//	var n int
+//	_ = a == n
+type ErrComparison struct {
+	LeftType  types.Type
+	RightType types.Type
+	Expr      *ast.BinaryExpr
+}
+
+// Node returns the comparison.
+func (e *ErrComparison) Node() ast.Expr {
+	return e.Expr
+}
+
+func (*ErrComparison) typ() typErr {
+	return TypeErrComparison
+}
+
+// ErrUnary represents overflow of unary negation of unsigned constant.
+//
+// Example:
+//
+//	const c uint = 1
+//	_ = -c
+type ErrUnary struct {
+	OperandType types.Type
+	// WantType is the signed integer type which the operand is converted
+	// to. It's the type which the context of the negation expects if it's
+	// a signed integer type, or the signed type of the same size otherwise.
+	WantType types.Type
+	Expr     *ast.UnaryExpr
+}
+
+// Node returns the negation.
+func (e *ErrUnary) Node() ast.Expr {
+	return e.Expr
+}
+
+func (*ErrUnary) typ() typErr {
+	return TypeErrUnary
+}
+
+// ErrIndex represents type error of index which is not an integer. The
+// index is an index of index expression, an index of slice expression or a
+// size argument of make. It also represents the key of map index expression
+// which is not assignable to the key type.
+//
+// Example:
+//
+//	var f float64
+//	_ = s[f]
+//	_ = s[1:f]
+//	_ = make([]int, f)
+//	_ = m[x] // m is map[int64]int and x is int
+type ErrIndex struct {
+	IndexType types.Type
+	// WantType is the type which the index is converted to. It's int or
+	// the key type of the map.
+	WantType types.Type
+	Index    ast.Expr
+	// Expr is the index expression, the slice expression or the call of
+	// make.
+	Expr ast.Expr
+}
+
+// Node returns the index.
+func (e *ErrIndex) Node() ast.Expr {
+	return e.Index
+}
+
+func (*ErrIndex) typ() typErr {
+	return TypeErrIndex
+}
+
+// ErrInterface represents type error of value which doesn't implement the
+// interface while the pointer to the value does, because the methods of the
+// interface have pointer receivers.
+//
+// Example:
+//
+//	type I interface{ M() }
+//	type T struct{}
+//	func (*T) M() {}
+//
+//	var t T
+//	var _ I = t
+type ErrInterface struct {
+	IfaceType types.Type
+	ValueType types.Type
+	Value     ast.Expr
+	// Context is the context where the value is used.
+	Context Context
+}
+
+// Node returns the value.
+func (e *ErrInterface) Node() ast.Expr {
+	return e.Value
+}
+
+func (*ErrInterface) typ() typErr {
+	return TypeErrInterface
+}
+
+// contextOf returns the context of the conversion which fixes terr.
+func contextOf(terr TypeError) Context {
+	switch terr := terr.(type) {
+	case *ErrVarDecl:
+		return ContextVarDecl
+	case *ErrFuncArg:
+		return ContextFuncArg
+	case *ErrAssign:
+		return ContextAssign
+	case *ErrMismatched:
+		return ContextBinary
+	case *ErrReturn:
+		return ContextReturn
+	case *ErrCompositeLit:
+		return ContextCompositeLit
+	case *ErrSend:
+		return ContextSend
+	case *ErrMultiValue:
+		return terr.Context
+	case *ErrShiftOperand, *ErrShiftCount:
+		return ContextShift
+	case *ErrComparison:
+		return ContextComparison
+	case *ErrUnary:
+		return ContextUnary
+	case *ErrIndex:
+		return ContextIndex
+	case *ErrInterface:
+		return terr.Context
+	}
+	return ""
+}
+
+// errorCode is an error code of go/types.Error.
+//
+// See golang.org/x/tools/internal/typesinternal for the list of codes.
+type errorCode int
+
+const (
+	codeInvalidIfaceAssign  errorCode = 21
+	codeIncompatibleAssign  errorCode = 23
+	codeTruncatedFloat      errorCode = 43
+	codeNumericOverflow     errorCode = 44
+	codeMismatchedTypes     errorCode = 46
+	codeInvalidIndex        errorCode = 52
+	codeInvalidShiftCount   errorCode = 56
+	codeInvalidShiftOperand errorCode = 57
+	codeUnsupportedFeature  errorCode = 135
+)
+
+// readErrorData reads the error code and the interval of the offending node
+// from unexported fields of types.Error as typesinternal.ReadGo116ErrorData
+// does. It reports false if the data is not available.
+func readErrorData(err types.Error) (code errorCode, start, end token.Pos, ok bool) {
+	var data [3]int
+	v := reflect.ValueOf(err)
+	for i, name := range []string{"go116code", "go116start", "go116end"} {
+		f := v.FieldByName(name)
+		if !f.IsValid() {
+			return 0, 0, 0, false
+		}
+		data[i] = int(f.Int())
+	}
+	return errorCode(data[0]), token.Pos(data[1]), token.Pos(data[2]), true
+}
+
+// ErrorInterval returns the source interval [start, end) of the node which
+// caused err. It returns [err.Pos, err.Pos) if the interval is not available.
+func ErrorInterval(err types.Error) (start, end token.Pos) {
+	_, start, end, ok := readErrorData(err)
+	if !ok || !start.IsValid() || !end.IsValid() {
+		return err.Pos, err.Pos
+	}
+	return start, end
+}
+
+// errorPath returns the path from the node which caused err to the root of f.
+// go/types doesn't report the exact interval of the node for some errors
+// (e.g. multi-value assignment), so it falls back to the outermost expression
+// which starts at err.Pos.
+func errorPath(f *ast.File, err types.Error) []ast.Node {
+	start, end := ErrorInterval(err)
+	if f.FileStart <= start && end <= f.FileEnd {
+		if path, exact := astutil.PathEnclosingInterval(f, start, end); exact {
+			return path
+		}
+	}
+	path, _ := astutil.PathEnclosingInterval(f, err.Pos, err.Pos)
+	i := 0
+	for ; i+1 < len(path); i++ {
+		if x, ok := path[i+1].(ast.Expr); !ok || x.Pos() != err.Pos {
+			break
+		}
+	}
+	return path[i:]
+}
+
+// NewTypeErr creates TypeError from types.Error. It classifies err by its
+// error code and the offending node instead of the error message, so it
+// doesn't depend on the message text which differs between Go versions.
+//
+// path is the path from the offending node to the root of ast.File (see
+// ErrorInterval and Program.PathEnclosingInterval) and info is the type
+// information of the package where err occurs. It returns nil if err is not a
+// supported type conversion error.
+func NewTypeErr(err types.Error, path []ast.Node, info *types.Info) TypeError {
+	code, _, _, ok := readErrorData(err)
+	if !ok || len(path) == 0 {
+		return nil
+	}
+	switch code {
+	case codeIncompatibleAssign:
+		return newIncompatibleAssignErr(path, info)
+	case codeInvalidIfaceAssign:
+		return newInterfaceErr(newIncompatibleAssignErr(path, info))
+	case codeMismatchedTypes:
+		binaryexpr := mismatchedBinaryExpr(path, info)
+		if binaryexpr == nil {
+			return nil
+		}
+		ltyp, rtyp := info.TypeOf(binaryexpr.X), info.TypeOf(binaryexpr.Y)
+		if isComparison(binaryexpr.Op) && (isUnderlying(ltyp, rtyp) || isUnderlying(rtyp, ltyp)) {
+			return &ErrComparison{LeftType: ltyp, RightType: rtyp, Expr: binaryexpr}
+		}
+		var want types.Type
+		if !isComparison(binaryexpr.Op) {
+			for i, n := range path {
+				if n == binaryexpr {
+					want = expectedType(path[i:], info)
+					break
+				}
+			}
+		}
+		return &ErrMismatched{
+			LeftType:  ltyp,
+			RightType: rtyp,
+			WantType:  want,
+			Expr:      binaryexpr,
+		}
+	case codeInvalidShiftOperand:
+		shift, rest, left := shiftOperand(path)
+		x, ok := path[0].(ast.Expr)
+		if shift == nil || !left || !ok {
+			return nil
+		}
+		want := expectedType(rest, info)
+		if !isInteger(want) {
+			want = types.Typ[types.Int]
+		}
+		return &ErrShiftOperand{OperandType: info.TypeOf(x), WantType: want, Operand: x, Expr: shift}
+	case codeInvalidIndex:
+		return newIndexErr(path, info)
+	case codeInvalidShiftCount, codeTruncatedFloat, codeUnsupportedFeature:
+		shift, _, left := shiftOperand(path)
+		x, ok := path[0].(ast.Expr)
+		if shift == nil && code == codeTruncatedFloat {
+			if terr := newIndexErr(path, info); terr != nil {
+				return terr
+			}
+			return newConstantErr(path, info)
+		}
+		if shift == nil || left || !ok {
+			return nil
 		}
-		names := re.SubexpNames()
-		switch typErr(i) {
-		case TypeErrVarDecl:
-			return newErrVarDecl(ms, names)
-		case TypeErrFuncArg:
-			return newErrFuncArg(ms, names)
-		case TypeErrAssign:
-			return newErrAssign(ms, names)
-		case TypeErrMismatched:
-			return newErrMismatched(ms, names)
-		case TypeErrReturn:
-			return newErrReturn(ms, names)
+		got := info.TypeOf(x)
+		if code == codeUnsupportedFeature && (!isInteger(got) || isUnsigned(got)) {
+			return nil
 		}
+		return &ErrShiftCount{CountType: got, WantType: types.Typ[types.Uint], Count: x, Expr: shift}
+	case codeNumericOverflow:
+		neg, ok := path[0].(*ast.UnaryExpr)
+		if !ok || neg.Op != token.SUB || !isUnsigned(info.TypeOf(neg.X)) {
+			return newConstantErr(path, info)
+		}
+		got := info.TypeOf(neg.X)
+		want := expectedType(path, info)
+		if !isInteger(want) || isUnsigned(want) {
+			want = signedType(got)
+		}
+		return &ErrUnary{OperandType: got, WantType: want, Expr: neg}
 	}
 	return nil
 }
 
-func newErrVarDecl(matches, names []string) *ErrVarDecl {
-	err := &ErrVarDecl{}
-	for i, name := range names {
-		if i == 0 {
-			continue
+// newIndexErr creates ErrIndex for the index path[0] which is not an integer.
+// Negative constant indices are not supported since they cannot be fixed by
+// conversion.
+func newIndexErr(path []ast.Node, info *types.Info) TypeError {
+	x, ok := path[0].(ast.Expr)
+	if !ok {
+		return nil
+	}
+	got := info.TypeOf(x)
+	if got == nil || isInteger(got) {
+		return nil
+	}
+	idx := x
+	i := 1
+	for ; i < len(path); i++ {
+		paren, ok := path[i].(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		idx = paren
+	}
+	if i >= len(path) {
+		return nil
+	}
+	var expr ast.Expr
+	switch parent := path[i].(type) {
+	case *ast.IndexExpr:
+		if parent.Index == idx && !isMap(info.TypeOf(parent.X)) {
+			expr = parent
+		}
+	case *ast.SliceExpr:
+		if parent.Low == idx || parent.High == idx || parent.Max == idx {
+			expr = parent
 		}
-		m := matches[i]
-		switch name {
-		case "got":
-			err.ValueType = m
-		case "want":
-			err.NameType = m
+	case *ast.CallExpr:
+		if exprIndex(parent.Args, idx) > 0 && isBuiltin(parent, "make", info) {
+			expr = parent
 		}
 	}
-	return err
+	if expr == nil {
+		return nil
+	}
+	return &ErrIndex{IndexType: got, WantType: types.Typ[types.Int], Index: x, Expr: expr}
 }
 
-func newErrFuncArg(matches, names []string) *ErrFuncArg {
-	err := &ErrFuncArg{}
-	for i, name := range names {
-		if i == 0 {
-			continue
+// isMap reports whether the underlying type of t is map.
+func isMap(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	_, ok := t.Underlying().(*types.Map)
+	return ok
+}
+
+// isBuiltin reports whether call is a call of builtin function name.
+func isBuiltin(call *ast.CallExpr, name string, info *types.Info) bool {
+	id, ok := ast.Unparen(call.Fun).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	b, ok := info.Uses[id].(*types.Builtin)
+	return ok && b.Name() == name
+}
+
+// shiftOperand returns the shift expression whose operand is expression
+// path[0] (possibly parenthesized), and the path from the shift expression
+// to the root. It reports whether path[0] is the shifted operand. It returns
+// nil if path[0] isn't an operand of shift.
+func shiftOperand(path []ast.Node) (shift *ast.BinaryExpr, rest []ast.Node, left bool) {
+	x, ok := path[0].(ast.Expr)
+	if !ok {
+		return nil, nil, false
+	}
+	i := 1
+	for ; i < len(path); i++ {
+		paren, ok := path[i].(*ast.ParenExpr)
+		if !ok {
+			break
 		}
-		m := matches[i]
-		switch name {
-		case "got":
-			err.ArgType = m
-		case "want":
-			err.ParamType = m
+		x = paren
+	}
+	if i >= len(path) {
+		return nil, nil, false
+	}
+	shift, ok = path[i].(*ast.BinaryExpr)
+	if !ok || (shift.Op != token.SHL && shift.Op != token.SHR) {
+		return nil, nil, false
+	}
+	return shift, path[i:], shift.X == x
+}
+
+// isUnderlying reports whether t is the underlying type of named type n.
+func isUnderlying(t, n types.Type) bool {
+	return t != nil && n != nil && !types.Identical(t, n) && types.Identical(t, n.Underlying())
+}
+
+// isInteger reports whether t is a typed integer type.
+func isInteger(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsInteger != 0 && b.Info()&types.IsUntyped == 0
+}
+
+// isUnsigned reports whether t is a typed unsigned integer type.
+func isUnsigned(t types.Type) bool {
+	return isInteger(t) && t.Underlying().(*types.Basic).Info()&types.IsUnsigned != 0
+}
+
+// signedType returns the signed integer type of the same size as unsigned
+// integer type t.
+func signedType(t types.Type) types.Type {
+	switch t.Underlying().(*types.Basic).Kind() {
+	case types.Uint8:
+		return types.Typ[types.Int8]
+	case types.Uint16:
+		return types.Typ[types.Int16]
+	case types.Uint32:
+		return types.Typ[types.Int32]
+	case types.Uint64:
+		return types.Typ[types.Int64]
+	}
+	return types.Typ[types.Int]
+}
+
+// newIncompatibleAssignErr creates TypeError for the value path[0] which is
+// not assignable to its destination by looking up the destination type from
+// the parent node.
+func newIncompatibleAssignErr(path []ast.Node, info *types.Info) TypeError {
+	child := path[0]
+	i := 1
+	for ; i < len(path); i++ {
+		if _, ok := path[i].(*ast.ParenExpr); !ok {
+			break
 		}
+		child = path[i]
+	}
+	if i >= len(path) {
+		return nil
 	}
-	return err
+	expr, ok := child.(ast.Expr)
+	if !ok {
+		return nil
+	}
+	got := info.TypeOf(expr)
+	if got == nil {
+		return nil
+	}
+	if tuple, ok := got.(*types.Tuple); ok {
+		call, ok := expr.(*ast.CallExpr)
+		if !ok || expr != child {
+			return nil
+		}
+		return newMultiValueErr(call, tuple, path[i:], info)
+	}
+	switch parent := path[i].(type) {
+	case *ast.ValueSpec:
+		idx := exprIndex(parent.Values, expr)
+		if idx == -1 || parent.Type == nil {
+			return nil
+		}
+		if want := info.TypeOf(parent.Type); want != nil {
+			return &ErrVarDecl{NameType: want, ValueType: got, Value: expr, Spec: parent}
+		}
+	case *ast.CallExpr:
+		idx := exprIndex(parent.Args, expr)
+		if idx == -1 {
+			return nil
+		}
+		if want := paramType(parent, idx, info); want != nil {
+			return &ErrFuncArg{ParamType: want, ArgType: got, Arg: expr, Call: parent, Stmt: enclosingStmt(path[i:])}
+		}
+	case *ast.AssignStmt:
+		idx := exprIndex(parent.Rhs, expr)
+		if idx == -1 || len(parent.Lhs) != len(parent.Rhs) {
+			return nil
+		}
+		if want := info.TypeOf(parent.Lhs[idx]); want != nil {
+			return &ErrAssign{LeftType: want, RightType: got, Right: expr, Stmt: parent}
+		}
+	case *ast.ReturnStmt:
+		idx := exprIndex(parent.Results, expr)
+		if idx == -1 {
+			return nil
+		}
+		sig := enclosingSignature(path[i+1:], info)
+		if sig == nil || sig.Results().Len() != len(parent.Results) {
+			return nil
+		}
+		want := sig.Results().At(idx).Type()
+		return &ErrReturn{WantType: want, GotType: got, Result: expr, Stmt: parent}
+	case *ast.CompositeLit:
+		if idx := exprIndex(parent.Elts, expr); idx != -1 {
+			if want := elemType(parent, idx, nil, info); want != nil {
+				return &ErrCompositeLit{WantType: want, GotType: got, Elem: expr, Lit: parent}
+			}
+		}
+	case *ast.SendStmt:
+		if parent.Value != expr {
+			return nil
+		}
+		if ch, ok := info.TypeOf(parent.Chan).Underlying().(*types.Chan); ok {
+			return &ErrSend{ElemType: ch.Elem(), ValueType: got, Value: expr, Stmt: parent}
+		}
+	case *ast.IndexExpr:
+		if parent.Index != expr {
+			return nil
+		}
+		if m, ok := info.TypeOf(parent.X).Underlying().(*types.Map); ok {
+			return &ErrIndex{IndexType: got, WantType: m.Key(), Index: expr, Expr: parent}
+		}
+	case *ast.KeyValueExpr:
+		if i+1 >= len(path) {
+			return nil
+		}
+		lit, ok := path[i+1].(*ast.CompositeLit)
+		if !ok {
+			return nil
+		}
+		idx := exprIndex(lit.Elts, parent)
+		if idx == -1 {
+			return nil
+		}
+		if want := elemType(lit, idx, expr, info); want != nil {
+			return &ErrCompositeLit{WantType: want, GotType: got, Elem: expr, Lit: lit}
+		}
+	}
+	return nil
 }
 
-func newErrAssign(matches, names []string) *ErrAssign {
-	err := &ErrAssign{}
-	for i, name := range names {
-		if i == 0 {
-			continue
+// newConstantErr creates TypeError for the untyped constant path[0] which
+// overflows or is truncated by the type of its destination (e.g. var x uint
+// = -1). The fix reports the constant as unfixable.
+func newConstantErr(path []ast.Node, info *types.Info) TypeError {
+	x, ok := path[0].(ast.Expr)
+	if !ok {
+		return nil
+	}
+	if tv, ok := info.Types[x]; !ok || tv.Value == nil {
+		return nil
+	}
+	return newIncompatibleAssignErr(path, info)
+}
+
+// newInterfaceErr creates ErrInterface from terr, the error of the value
+// which is not assignable to its destination, if the destination is an
+// interface which only the pointer to the value implements.
+func newInterfaceErr(terr TypeError) TypeError {
+	var want, got types.Type
+	switch terr := terr.(type) {
+	case *ErrVarDecl:
+		want, got = terr.NameType, terr.ValueType
+	case *ErrFuncArg:
+		want, got = terr.ParamType, terr.ArgType
+	case *ErrAssign:
+		want, got = terr.LeftType, terr.RightType
+	case *ErrReturn:
+		want, got = terr.WantType, terr.GotType
+	case *ErrCompositeLit:
+		want, got = terr.WantType, terr.GotType
+	case *ErrSend:
+		want, got = terr.ElemType, terr.ValueType
+	case *ErrIndex:
+		want, got = terr.WantType, terr.IndexType
+	default:
+		return nil
+	}
+	iface, ok := want.Underlying().(*types.Interface)
+	if !ok || types.Implements(got, iface) || !types.Implements(types.NewPointer(got), iface) {
+		return nil
+	}
+	return &ErrInterface{IfaceType: want, ValueType: got, Value: terr.Node(), Context: contextOf(terr)}
+}
+
+// newMultiValueErr creates ErrMultiValue for call of multi-value function.
+// path is the path from the parent node of call to the root of ast.File.
+func newMultiValueErr(call *ast.CallExpr, tuple *types.Tuple, path []ast.Node, info *types.Info) TypeError {
+	var want []types.Type
+	var ctx Context
+	switch parent := path[0].(type) {
+	case *ast.CallExpr:
+		ctx = ContextFuncArg
+		if len(parent.Args) != 1 || parent.Ellipsis.IsValid() {
+			return nil
+		}
+		for i := 0; i < tuple.Len(); i++ {
+			want = append(want, paramType(parent, i, info))
 		}
-		m := matches[i]
-		switch name {
-		case "got":
-			err.RightType = m
-		case "want":
-			err.LeftType = m
+	case *ast.ReturnStmt:
+		ctx = ContextReturn
+		if len(parent.Results) != 1 {
+			return nil
+		}
+		sig := enclosingSignature(path[1:], info)
+		if sig == nil {
+			return nil
+		}
+		for i := 0; i < sig.Results().Len(); i++ {
+			want = append(want, sig.Results().At(i).Type())
+		}
+	case *ast.AssignStmt:
+		ctx = ContextAssign
+		if len(parent.Rhs) != 1 || parent.Tok != token.ASSIGN {
+			return nil
+		}
+		for _, lhs := range parent.Lhs {
+			want = append(want, info.TypeOf(lhs))
+		}
+	case *ast.ValueSpec:
+		ctx = ContextVarDecl
+		if len(parent.Values) != 1 || parent.Type == nil {
+			return nil
+		}
+		typ := info.TypeOf(parent.Type)
+		for range parent.Names {
+			want = append(want, typ)
+		}
+	default:
+		return nil
+	}
+	if len(want) != tuple.Len() {
+		return nil
+	}
+	for i, t := range want {
+		if t == nil && !isBlankDest(path[0], i) {
+			return nil
 		}
 	}
-	return err
+	return &ErrMultiValue{WantTypes: want, GotTypes: tuple, Call: call, Stmt: enclosingStmt(path), Context: ctx}
 }
 
-func newErrMismatched(matches, names []string) *ErrMismatched {
-	err := &ErrMismatched{}
-	for i, name := range names {
-		if i == 0 {
+// isBlankDest reports whether the idx-th destination of assignment node is
+// blank identifier.
+func isBlankDest(node ast.Node, idx int) bool {
+	var x ast.Expr
+	switch node := node.(type) {
+	case *ast.AssignStmt:
+		x = node.Lhs[idx]
+	case *ast.ValueSpec:
+		x = node.Names[idx]
+	}
+	id, ok := x.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+// enclosingStmt returns the innermost statement in path which belongs to
+// statement list or nil if there is no such statement.
+func enclosingStmt(path []ast.Node) ast.Stmt {
+	for i, n := range path {
+		stmt, ok := n.(ast.Stmt)
+		if !ok || i+1 >= len(path) {
 			continue
 		}
-		m := matches[i]
-		switch name {
-		case "left":
-			err.LeftType = m
-		case "right":
-			err.RightType = m
+		switch path[i+1].(type) {
+		case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+			return stmt
 		}
 	}
-	return err
+	return nil
 }
 
-func newErrReturn(matches, names []string) *ErrReturn {
-	err := &ErrReturn{}
-	for i, name := range names {
-		if i == 0 {
-			continue
+// mismatchedBinaryExpr returns the binary expression of mismatched types
+// error. go/types reports the error at the binary expression for arithmetic
+// operators and at one of its operands for comparison operators.
+func mismatchedBinaryExpr(path []ast.Node, info *types.Info) *ast.BinaryExpr {
+	if binaryexpr, ok := path[0].(*ast.BinaryExpr); ok && isMismatched(binaryexpr, info) {
+		return binaryexpr
+	}
+	if len(path) < 2 {
+		return nil
+	}
+	if binaryexpr, ok := path[1].(*ast.BinaryExpr); ok && (binaryexpr.X == path[0] || binaryexpr.Y == path[0]) && isMismatched(binaryexpr, info) {
+		return binaryexpr
+	}
+	return nil
+}
+
+// isComparison reports whether op is comparison or logical operator whose
+// result is untyped boolean.
+func isComparison(op token.Token) bool {
+	switch op {
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ, token.LAND, token.LOR:
+		return true
+	}
+	return false
+}
+
+// expectedType returns the type which the parent of expression path[0]
+// expects, or nil if it's unknown.
+func expectedType(path []ast.Node, info *types.Info) types.Type {
+	expr, ok := path[0].(ast.Expr)
+	if !ok {
+		return nil
+	}
+	i := 1
+	for ; i < len(path); i++ {
+		paren, ok := path[i].(*ast.ParenExpr)
+		if !ok {
+			break
 		}
-		m := matches[i]
-		switch name {
-		case "want":
-			err.WantType = m
-		case "got":
-			err.GotType = m
+		expr = paren
+	}
+	if i >= len(path) {
+		return nil
+	}
+	switch parent := path[i].(type) {
+	case *ast.ValueSpec:
+		if parent.Type != nil && exprIndex(parent.Values, expr) != -1 {
+			return info.TypeOf(parent.Type)
+		}
+	case *ast.CallExpr:
+		if idx := exprIndex(parent.Args, expr); idx != -1 && !parent.Ellipsis.IsValid() {
+			return paramType(parent, idx, info)
+		}
+	case *ast.AssignStmt:
+		idx := exprIndex(parent.Rhs, expr)
+		if idx == -1 || len(parent.Lhs) != len(parent.Rhs) || parent.Tok == token.DEFINE {
+			return nil
+		}
+		return info.TypeOf(parent.Lhs[idx])
+	case *ast.ReturnStmt:
+		idx := exprIndex(parent.Results, expr)
+		if idx == -1 {
+			return nil
+		}
+		if sig := enclosingSignature(path[i+1:], info); sig != nil && sig.Results().Len() == len(parent.Results) {
+			return sig.Results().At(idx).Type()
+		}
+	case *ast.CompositeLit:
+		if idx := exprIndex(parent.Elts, expr); idx != -1 {
+			return elemType(parent, idx, nil, info)
+		}
+	case *ast.KeyValueExpr:
+		if i+1 < len(path) {
+			if lit, ok := path[i+1].(*ast.CompositeLit); ok {
+				if idx := exprIndex(lit.Elts, parent); idx != -1 {
+					return elemType(lit, idx, expr, info)
+				}
+			}
+		}
+	case *ast.SendStmt:
+		if parent.Value == expr {
+			if ch, ok := info.TypeOf(parent.Chan).Underlying().(*types.Chan); ok {
+				return ch.Elem()
+			}
 		}
 	}
-	return err
+	return nil
+}
+
+func isMismatched(binaryexpr *ast.BinaryExpr, info *types.Info) bool {
+	ltyp, rtyp := info.TypeOf(binaryexpr.X), info.TypeOf(binaryexpr.Y)
+	return ltyp != nil && rtyp != nil && !types.Identical(ltyp, rtyp)
+}
+
+// exprIndex returns the index of x in exprs or -1 if exprs doesn't contain x.
+func exprIndex(exprs []ast.Expr, x ast.Expr) int {
+	for i, e := range exprs {
+		if e == x {
+			return i
+		}
+	}
+	return -1
+}
+
+// paramType returns the type of parameter for idx-th argument of call.
+func paramType(call *ast.CallExpr, idx int, info *types.Info) types.Type {
+	typ := info.TypeOf(call.Fun)
+	if typ == nil {
+		return nil
+	}
+	sig, ok := typ.Underlying().(*types.Signature)
+	if !ok {
+		return nil
+	}
+	params := sig.Params()
+	if sig.Variadic() && idx >= params.Len()-1 {
+		last := params.At(params.Len() - 1).Type()
+		if call.Ellipsis.IsValid() {
+			return last
+		}
+		if s, ok := last.Underlying().(*types.Slice); ok {
+			return s.Elem()
+		}
+		return nil
+	}
+	if idx >= params.Len() {
+		return nil
+	}
+	return params.At(idx).Type()
+}
+
+// elemType returns the type of idx-th element of composite literal lit. If
+// the element is a key-value pair, x is its key or value.
+func elemType(lit *ast.CompositeLit, idx int, x ast.Expr, info *types.Info) types.Type {
+	typ := info.TypeOf(lit)
+	if typ == nil {
+		return nil
+	}
+	if p, ok := typ.Underlying().(*types.Pointer); ok {
+		// elided &T in composite literal.
+		typ = p.Elem()
+	}
+	kv, _ := lit.Elts[idx].(*ast.KeyValueExpr)
+	switch t := typ.Underlying().(type) {
+	case *types.Struct:
+		if kv == nil {
+			if idx < t.NumFields() {
+				return t.Field(idx).Type()
+			}
+			return nil
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok || x != kv.Value {
+			return nil
+		}
+		for i := 0; i < t.NumFields(); i++ {
+			if f := t.Field(i); f.Name() == key.Name {
+				return f.Type()
+			}
+		}
+	case *types.Array:
+		if kv == nil || x == kv.Value {
+			return t.Elem()
+		}
+	case *types.Slice:
+		if kv == nil || x == kv.Value {
+			return t.Elem()
+		}
+	case *types.Map:
+		if kv == nil {
+			return nil
+		}
+		if x == kv.Key {
+			return t.Key()
+		}
+		return t.Elem()
+	}
+	return nil
+}
+
+// enclosingSignature returns the signature of the innermost function in path.
+func enclosingSignature(path []ast.Node, info *types.Info) *types.Signature {
+	for _, n := range path {
+		var typ types.Type
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			typ = info.TypeOf(n)
+		case *ast.FuncDecl:
+			if obj := info.Defs[n.Name]; obj != nil {
+				typ = obj.Type()
+			}
+		default:
+			continue
+		}
+		sig, _ := typ.(*types.Signature)
+		return sig
+	}
+	return nil
 }
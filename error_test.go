@@ -1,50 +1,424 @@
 package typeconv
 
 import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
 	"go/types"
+	"strings"
 	"testing"
 )
 
+// checkSrc type-checks src and returns the TypeError for each types.Error.
+// It clears types.Error.Msg to ensure TypeError doesn't depend on it.
+func checkSrc(t *testing.T, src string) []TypeError {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var typeErrs []types.Error
+	conf := types.Config{
+		Importer: importer.Default(),
+		Error: func(err error) {
+			typeErrs = append(typeErrs, err.(types.Error))
+		},
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	conf.Check("src", fset, []*ast.File{f}, info)
+	var terrs []TypeError
+	for _, e := range typeErrs {
+		e.Msg = ""
+		path := errorPath(f, e)
+		terrs = append(terrs, NewTypeErr(e, path, info))
+	}
+	return terrs
+}
+
+// typeStrings returns the string representation of the types of terr.
+func typeStrings(terr TypeError) [2]string {
+	qf := func(*types.Package) string { return "" }
+	switch terr := terr.(type) {
+	case *ErrVarDecl:
+		return [2]string{types.TypeString(terr.NameType, qf), types.TypeString(terr.ValueType, qf)}
+	case *ErrFuncArg:
+		return [2]string{types.TypeString(terr.ParamType, qf), types.TypeString(terr.ArgType, qf)}
+	case *ErrAssign:
+		return [2]string{types.TypeString(terr.LeftType, qf), types.TypeString(terr.RightType, qf)}
+	case *ErrMismatched:
+		return [2]string{types.TypeString(terr.LeftType, qf), types.TypeString(terr.RightType, qf)}
+	case *ErrReturn:
+		return [2]string{types.TypeString(terr.WantType, qf), types.TypeString(terr.GotType, qf)}
+	case *ErrCompositeLit:
+		return [2]string{types.TypeString(terr.WantType, qf), types.TypeString(terr.GotType, qf)}
+	case *ErrSend:
+		return [2]string{types.TypeString(terr.ElemType, qf), types.TypeString(terr.ValueType, qf)}
+	case *ErrMultiValue:
+		var want []string
+		for _, t := range terr.WantTypes {
+			if t == nil {
+				want = append(want, "_")
+				continue
+			}
+			want = append(want, types.TypeString(t, qf))
+		}
+		return [2]string{"(" + strings.Join(want, ", ") + ")", types.TypeString(terr.GotTypes, qf)}
+	case *ErrShiftOperand:
+		return [2]string{types.TypeString(terr.WantType, qf), types.TypeString(terr.OperandType, qf)}
+	case *ErrShiftCount:
+		return [2]string{types.TypeString(terr.WantType, qf), types.TypeString(terr.CountType, qf)}
+	case *ErrComparison:
+		return [2]string{types.TypeString(terr.LeftType, qf), types.TypeString(terr.RightType, qf)}
+	case *ErrUnary:
+		return [2]string{types.TypeString(terr.WantType, qf), types.TypeString(terr.OperandType, qf)}
+	case *ErrIndex:
+		return [2]string{types.TypeString(terr.WantType, qf), types.TypeString(terr.IndexType, qf)}
+	case *ErrInterface:
+		return [2]string{types.TypeString(terr.IfaceType, qf), types.TypeString(terr.ValueType, qf)}
+	}
+	return [2]string{}
+}
+
 func TestNewTypeErr(t *testing.T) {
 	tests := []struct {
-		in      string
-		wantTyp typErr
+		src      string
+		wantTyp  typErr
+		wantNode string
+		// want types of both sides. e.g. [want, got] or [left, right]
+		wantTypes [2]string
 	}{
 		{
-			in:      "cannot use x (variable of type int) as uint value in variable declaration",
-			wantTyp: TypeErrVarDecl,
+			src:       "var x int; var _ uint = x",
+			wantTyp:   TypeErrVarDecl,
+			wantNode:  "x",
+			wantTypes: [2]string{"uint", "int"},
+		},
+		{
+			src:       "var x, y int; var _ float64 = x*x + y*y",
+			wantTyp:   TypeErrVarDecl,
+			wantNode:  "x * x + y * y",
+			wantTypes: [2]string{"float64", "int"},
+		},
+		{
+			src:       "type T int; var _ T = int(1)",
+			wantTyp:   TypeErrVarDecl,
+			wantNode:  "int(1)",
+			wantTypes: [2]string{"T", "int"},
+		},
+		{
+			src:       "func funcarg(x float64) {}; func f(x int) { funcarg(x) }",
+			wantTyp:   TypeErrFuncArg,
+			wantNode:  "x",
+			wantTypes: [2]string{"float64", "int"},
+		},
+		{
+			src:       "func funcarg(x int, ys ...int64) {}; func f(x int) { funcarg(x, 1, x) }",
+			wantTyp:   TypeErrFuncArg,
+			wantNode:  "x",
+			wantTypes: [2]string{"int64", "int"},
+		},
+		{
+			src:       "func f(xs []int) { _ = append([]int64{}, xs...) }",
+			wantTyp:   TypeErrFuncArg,
+			wantNode:  "xs",
+			wantTypes: [2]string{"[]int64", "[]int"},
+		},
+		{
+			src:       "func f(x float64, y int) { x = y }",
+			wantTyp:   TypeErrAssign,
+			wantNode:  "y",
+			wantTypes: [2]string{"float64", "int"},
+		},
+		{
+			src:       "func f(x float64, y int) { y, x = 1, y }",
+			wantTyp:   TypeErrAssign,
+			wantNode:  "y",
+			wantTypes: [2]string{"float64", "int"},
+		},
+		{
+			src:       "func f(x int, y float64) { _ = x * y }",
+			wantTyp:   TypeErrMismatched,
+			wantNode:  "x * y",
+			wantTypes: [2]string{"int", "float64"},
 		},
 		{
-			in:      "cannot use x * x + y * y (value of type int) as float64 value in variable declaration",
-			wantTyp: TypeErrVarDecl,
+			src:       "func f(x int, y float64) { _ = x+1 == y }",
+			wantTyp:   TypeErrMismatched,
+			wantNode:  "x + 1 == y",
+			wantTypes: [2]string{"int", "float64"},
 		},
 		{
-			in:      "cannot use x (variable of type int) as float64 value in argument to funcarg",
-			wantTyp: TypeErrFuncArg,
+			src:       "func f(x int) float64 { return x }",
+			wantTyp:   TypeErrReturn,
+			wantNode:  "x",
+			wantTypes: [2]string{"float64", "int"},
 		},
 		{
-			in:      "cannot use y (variable of type int) as float64 value in assignment",
-			wantTyp: TypeErrAssign,
+			src:       "func f(x int) { _ = func() (int64, error) { return (x), nil } }",
+			wantTyp:   TypeErrReturn,
+			wantNode:  "(x)",
+			wantTypes: [2]string{"int64", "int"},
 		},
 		{
-			in:      "invalid operation: mismatched types int and float64",
-			wantTyp: TypeErrMismatched,
+			src:       "func f(x int) (a, b int64) { if x > 0 { return 1, x }; return }",
+			wantTyp:   TypeErrReturn,
+			wantNode:  "x",
+			wantTypes: [2]string{"int64", "int"},
 		},
 		{
-			in:      "cannot use x (variable of type int) as float64 value in return statement",
-			wantTyp: TypeErrReturn,
+			src:       "type T struct{ n int }; func (t T) f() (uint, error) { return t.n, nil }",
+			wantTyp:   TypeErrReturn,
+			wantNode:  "t.n",
+			wantTypes: [2]string{"uint", "int"},
+		},
+		{
+			src:       "func f() (int, error); func g(x int64, err error); func h() { g(f()) }",
+			wantTyp:   TypeErrMultiValue,
+			wantNode:  "f()",
+			wantTypes: [2]string{"(int64, error)", "(int, error)"},
+		},
+		{
+			src:       "func f() (int, error); func h() (int64, error) { return f() }",
+			wantTyp:   TypeErrMultiValue,
+			wantNode:  "f()",
+			wantTypes: [2]string{"(int64, error)", "(int, error)"},
+		},
+		{
+			src:       "func f() (int, error); func h() { var x int64; x, _ = f(); _ = x }",
+			wantTyp:   TypeErrMultiValue,
+			wantNode:  "f()",
+			wantTypes: [2]string{"(int64, _)", "(int, error)"},
+		},
+		{
+			src:       `var _ int = "string"`,
+			wantTyp:   TypeErrVarDecl,
+			wantNode:  `"string"`,
+			wantTypes: [2]string{"int", "untyped string"},
+		},
+		{
+			src:       "type T struct{ A, B int64 }; func f(x int) { _ = T{A: 1, B: x} }",
+			wantTyp:   TypeErrCompositeLit,
+			wantNode:  "x",
+			wantTypes: [2]string{"int64", "int"},
+		},
+		{
+			src:       "type T struct{ A, B int64 }; func f(x int) { _ = T{1, x} }",
+			wantTyp:   TypeErrCompositeLit,
+			wantNode:  "x",
+			wantTypes: [2]string{"int64", "int"},
+		},
+		{
+			src:       "func f(x int) { _ = []int64{1, 2: x} }",
+			wantTyp:   TypeErrCompositeLit,
+			wantNode:  "x",
+			wantTypes: [2]string{"int64", "int"},
+		},
+		{
+			src:       "func f(x int) { _ = [...]float64{x} }",
+			wantTyp:   TypeErrCompositeLit,
+			wantNode:  "x",
+			wantTypes: [2]string{"float64", "int"},
+		},
+		{
+			src:       "func f(x int) { _ = map[int64]string{x: \"\"} }",
+			wantTyp:   TypeErrCompositeLit,
+			wantNode:  "x",
+			wantTypes: [2]string{"int64", "int"},
+		},
+		{
+			src:       "func f(x int) { _ = map[string]int64{\"\": x} }",
+			wantTyp:   TypeErrCompositeLit,
+			wantNode:  "x",
+			wantTypes: [2]string{"int64", "int"},
+		},
+		{
+			src:       "type T struct{ A int64 }; func f(x int) { _ = []*T{{A: x}} }",
+			wantTyp:   TypeErrCompositeLit,
+			wantNode:  "x",
+			wantTypes: [2]string{"int64", "int"},
+		},
+		{
+			src:       "func f(ch chan<- int64, x int) { ch <- x }",
+			wantTyp:   TypeErrSend,
+			wantNode:  "x",
+			wantTypes: [2]string{"int64", "int"},
+		},
+		{
+			src:       "func f(x float64) { _ = x << 2 }",
+			wantTyp:   TypeErrShiftOperand,
+			wantNode:  "x",
+			wantTypes: [2]string{"int", "float64"},
+		},
+		{
+			src:       "func f(x float64, n int) { var _ uint8 = (x) << n }",
+			wantTyp:   TypeErrShiftOperand,
+			wantNode:  "(x)",
+			wantTypes: [2]string{"uint8", "float64"},
+		},
+		{
+			src:       "func f(x int, y float64) { _ = x >> (y) }",
+			wantTyp:   TypeErrShiftCount,
+			wantNode:  "(y)",
+			wantTypes: [2]string{"uint", "float64"},
+		},
+		{
+			src:       "func f(x int) { _ = x << -1 }",
+			wantTyp:   TypeErrShiftCount,
+			wantNode:  "-1",
+			wantTypes: [2]string{"uint", "untyped int"},
+		},
+		{
+			src:       "func f(x int) { _ = x << 1.5 }",
+			wantTyp:   TypeErrShiftCount,
+			wantNode:  "1.5",
+			wantTypes: [2]string{"uint", "untyped float"},
+		},
+		{
+			src:       "type T int; func f(x T, y int) { _ = x == y }",
+			wantTyp:   TypeErrComparison,
+			wantNode:  "x == y",
+			wantTypes: [2]string{"T", "int"},
+		},
+		{
+			src:       "type T int; func f(x T, y int) { _ = y < x }",
+			wantTyp:   TypeErrComparison,
+			wantNode:  "y < x",
+			wantTypes: [2]string{"int", "T"},
+		},
+		{
+			src:       "type T int; type U int; func f(x T, y U) { _ = x == y }",
+			wantTyp:   TypeErrMismatched,
+			wantNode:  "x == y",
+			wantTypes: [2]string{"T", "U"},
+		},
+		{
+			src:       "const c uint16 = 1; var _ = -c",
+			wantTyp:   TypeErrUnary,
+			wantNode:  "-c",
+			wantTypes: [2]string{"int16", "uint16"},
+		},
+		{
+			src:       "const c uint16 = 1; var _ int64 = -c",
+			wantTyp:   TypeErrUnary,
+			wantNode:  "-c",
+			wantTypes: [2]string{"int64", "uint16"},
+		},
+		{
+			src:       "func f(s []int, x float64) { _ = s[x] }",
+			wantTyp:   TypeErrIndex,
+			wantNode:  "x",
+			wantTypes: [2]string{"int", "float64"},
+		},
+		{
+			src:       "func f(s string, x float64) { _ = s[1:(x)] }",
+			wantTyp:   TypeErrIndex,
+			wantNode:  "(x)",
+			wantTypes: [2]string{"int", "float64"},
+		},
+		{
+			src:       "func f(x float64) { _ = make([]int, x) }",
+			wantTyp:   TypeErrIndex,
+			wantNode:  "x",
+			wantTypes: [2]string{"int", "float64"},
+		},
+		{
+			src:       "func f(m map[int64]bool, x int) { _ = m[x] }",
+			wantTyp:   TypeErrIndex,
+			wantNode:  "x",
+			wantTypes: [2]string{"int64", "int"},
+		},
+		{
+			src:       "type I interface{ M() }; type T struct{}; func (*T) M() {}; func f(t T) { var _ I = t }",
+			wantTyp:   TypeErrInterface,
+			wantNode:  "t",
+			wantTypes: [2]string{"I", "T"},
+		},
+		{
+			src:       "type I interface{ M() }; type T struct{}; func (*T) M() {}; func f(t T) I { return t }",
+			wantTyp:   TypeErrInterface,
+			wantNode:  "t",
+			wantTypes: [2]string{"I", "T"},
+		},
+		{
+			src:     "type I interface{ M() }; type T struct{}; func f(t T) { var _ I = t }",
+			wantTyp: -1,
+		},
+		{
+			src:     "func f(s []int) { _ = s[-1] }",
+			wantTyp: -1,
+		},
+		{
+			src:     "func f(x int, y float64) { x += y }",
+			wantTyp: -1,
 		},
 	}
 
 	for _, tt := range tests {
-		terr := NewTypeErr(types.Error{Msg: tt.in})
+		terrs := checkSrc(t, "package src; "+tt.src)
+		if len(terrs) != 1 {
+			t.Errorf("%s: got %d errors, want 1", tt.src, len(terrs))
+			continue
+		}
+		terr := terrs[0]
+		if tt.wantTyp == -1 {
+			if terr != nil {
+				t.Errorf("%s: got %#v, want nil", tt.src, terr)
+			}
+			continue
+		}
 		if terr == nil {
-			t.Errorf("got nil. in: %v", tt.in)
+			t.Errorf("%s: got nil", tt.src)
 			continue
 		}
 		if got := terr.typ(); got != tt.wantTyp {
-			t.Errorf("type: got %v, want %v", got, tt.wantTyp)
+			t.Errorf("%s: type: got %v, want %v", tt.src, got, tt.wantTyp)
+		}
+		if got := types.ExprString(terr.Node()); got != tt.wantNode {
+			t.Errorf("%s: node: got %v, want %v", tt.src, got, tt.wantNode)
+		}
+		if got := typeStrings(terr); got != tt.wantTypes {
+			t.Errorf("%s: types: got %v, want %v", tt.src, got, tt.wantTypes)
 		}
 	}
+}
 
+func TestNewTypeErr_mismatchedWantType(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string // empty if WantType is nil
+	}{
+		{"func f(x int8, y int16) { var _ float64 = x + y }", "float64"},
+		{"func f(x int8, y int16) { var z float32; z = (x * y); _ = z }", "float32"},
+		{"func f(x int8, y int16) { g(x - y) }; func g(float64) {}", "float64"},
+		{"func f(x int8, y int16) int64 { return x / y }", "int64"},
+		{"func f(x int8, y int16) { _ = map[string]uint{\"a\": x + y} }", "uint"},
+		{"func f(ch chan int32, x int8, y int16) { ch <- x + y }", "int32"},
+		{"func f(x int8, y int16) { z := x + y; _ = z }", ""},
+		{"func f(x int8, y int16) { _ = x < y }", ""},
+		{"func f(x int8, y int16) { _ = (x + y) * 2 }", ""},
+	}
+	for _, tt := range tests {
+		terrs := checkSrc(t, "package src; "+tt.src)
+		if len(terrs) != 1 {
+			t.Errorf("%s: got %d errors, want 1", tt.src, len(terrs))
+			continue
+		}
+		terr, ok := terrs[0].(*ErrMismatched)
+		if !ok {
+			t.Errorf("%s: got %#v, want *ErrMismatched", tt.src, terrs[0])
+			continue
+		}
+		got := ""
+		if terr.WantType != nil {
+			got = terr.WantType.String()
+		}
+		if got != tt.want {
+			t.Errorf("%s: WantType == %q, want %q", tt.src, got, tt.want)
+		}
+	}
 }
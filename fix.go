@@ -0,0 +1,582 @@
+package typeconv
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// Fix represents a proposed fix of a type conversion error.
+type Fix struct {
+	// Filename is the name of the file to fix.
+	Filename string
+	// Pos and End are the source range of the node to fix.
+	Pos, End token.Pos
+	// Original is the source text of the node to fix.
+	Original string
+	// Replacement is the source text which replaces Original.
+	Replacement string
+	// Err is the type error which triggered the fix.
+	Err TypeError
+	// Rule is the type conversion rule used by the fix (e.g. "int -> int64").
+	Rule string
+
+	// Edits are the text edits in [Pos, End) which make Replacement from
+	// Original.
+	Edits []Edit
+	// Imports are the imports which Replacement requires but the file
+	// doesn't have yet.
+	Imports []Import
+	// Helpers are the names of conversion helper functions which
+	// Replacement requires but the package doesn't have yet. They are
+	// generated in HelperFilename of the package (see HelperFiles).
+	Helpers []string
+	// Safety is the safety class of the conversions which the fix emits.
+	Safety Safety
+	// Unfixable describes why the error cannot be fixed (e.g. the constant
+	// overflows the type). The fix has no edits if it's not empty.
+	Unfixable string
+}
+
+// Edit represents a text edit which replaces the text in [Pos, End) with
+// NewText.
+type Edit struct {
+	Pos, End token.Pos
+	NewText  string
+}
+
+// Import represents an import declaration.
+type Import struct {
+	Name string // empty if the import doesn't need an explicit name
+	Path string
+}
+
+// Package holds a type-checked package to fix. It's useful to fix packages
+// which are not loaded by Load (e.g. go/analysis).
+type Package struct {
+	Fset      *token.FileSet
+	Files     []*ast.File
+	Types     *types.Package
+	TypesInfo *types.Info
+	// ReadFile returns the content of the file. ioutil.ReadFile is used if
+	// it's nil.
+	ReadFile func(filename string) ([]byte, error)
+}
+
+// Options holds the options of fixes.
+type Options struct {
+	// SpreadSlice enables fixes of spread slice arguments of variadic
+	// function (e.g. f(xs...)) which convert the slice to a new slice before
+	// the statement.
+	SpreadSlice bool
+	// Lossy is the policy for lossy conversions (see Classify).
+	Lossy LossyPolicy
+	// Contexts are the contexts to fix. All contexts are fixed if it's
+	// empty.
+	Contexts []Context
+	// Exclude holds the patterns of files not to fix. A pattern matches a
+	// file by filepath.Match or if it's a directory containing the file. A
+	// pattern without path separators (e.g. *_gen.go) matches the base name
+	// of the file.
+	Exclude []string
+}
+
+// enabled reports whether opts enables fixes in ctx.
+func (opts *Options) enabled(ctx Context) bool {
+	if len(opts.Contexts) == 0 {
+		return true
+	}
+	for _, c := range opts.Contexts {
+		if c == ctx {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded reports whether opts excludes the file.
+func (opts *Options) excluded(filename string) bool {
+	for _, pattern := range opts.Exclude {
+		if !strings.ContainsAny(pattern, "/"+string(filepath.Separator)) {
+			if ok, _ := filepath.Match(pattern, filepath.Base(filename)); ok {
+				return true
+			}
+			continue
+		}
+		if ok, _ := filepath.Match(pattern, filename); ok {
+			return true
+		}
+		if dir := strings.TrimSuffix(pattern, "..."); strings.HasPrefix(filename, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fixes returns the fixes of typeErrs in prog ordered by their positions
+// with the default rule and options. It doesn't modify prog.
+func Fixes(prog *Program, typeErrs []types.Error) ([]*Fix, error) {
+	return (&Rewriter{}).Fixes(prog, typeErrs)
+}
+
+// Fixes returns the fixes of typeErrs in prog ordered by their positions.
+// It doesn't modify prog.
+func (r *Rewriter) Fixes(prog *Program, typeErrs []types.Error) ([]*Fix, error) {
+	pkgErrs := make(map[*packages.Package][]types.Error)
+	for _, e := range typeErrs {
+		pkg, _, _ := prog.PathEnclosingInterval(e.Pos, e.Pos)
+		if pkg == nil {
+			return nil, fmt.Errorf("cannot get node position for type error: %v", e)
+		}
+		pkgErrs[pkg] = append(pkgErrs[pkg], e)
+	}
+	var fixes []*Fix
+	for _, pkg := range prog.Packages {
+		errs, ok := pkgErrs[pkg]
+		if !ok {
+			continue
+		}
+		p := &Package{
+			Fset:      prog.Fset,
+			Files:     pkg.Syntax,
+			Types:     pkg.Types,
+			TypesInfo: pkg.TypesInfo,
+			ReadFile:  prog.ReadFile,
+		}
+		fs, err := r.PackageFixes(p, errs)
+		if err != nil {
+			return nil, err
+		}
+		fixes = append(fixes, fs...)
+	}
+	sortFixes(fixes)
+	return fixes, nil
+}
+
+// Fixes returns the fixes of typeErrs in the package ordered by their
+// positions with the default rule and options.
+func (pkg *Package) Fixes(typeErrs []types.Error) ([]*Fix, error) {
+	return (&Rewriter{}).PackageFixes(pkg, typeErrs)
+}
+
+// PackageFixes returns the fixes of typeErrs in the package ordered by their
+// positions.
+func (r *Rewriter) PackageFixes(pkg *Package, typeErrs []types.Error) ([]*Fix, error) {
+	rule := r.rule()
+	readFile := pkg.ReadFile
+	if readFile == nil {
+		readFile = ioutil.ReadFile
+	}
+	ctxs := make(map[*ast.File]*fileCtx)
+	// go/types may report multiple errors for a node (e.g. each result of
+	// multi-value call).
+	seen := make(map[ast.Node]bool)
+	var fixes []*Fix
+	for _, e := range typeErrs {
+		f := pkg.file(e.Pos, e.Pos)
+		if f == nil {
+			return nil, fmt.Errorf("cannot get node position for type error: %v", e)
+		}
+		if r.Options.excluded(pkg.Fset.File(f.Pos()).Name()) {
+			r.logf("%v: skip excluded file: %s", pkg.Fset.Position(e.Pos), e.Msg)
+			continue
+		}
+		path := errorPath(f, e)
+
+		terr := NewTypeErr(e, path, pkg.TypesInfo)
+		if terr == nil {
+			r.logf("%v: skip unsupported error: %s", pkg.Fset.Position(e.Pos), e.Msg)
+			continue
+		}
+		if seen[terr.Node()] {
+			continue
+		}
+		seen[terr.Node()] = true
+
+		c, ok := ctxs[f]
+		if !ok {
+			filename := pkg.Fset.File(f.Pos()).Name()
+			src, err := readFile(filename)
+			if err != nil {
+				return nil, err
+			}
+			c = &fileCtx{
+				fset:     pkg.Fset,
+				file:     f,
+				filename: filename,
+				src:      src,
+				pkg:      pkg.Types,
+				info:     pkg.TypesInfo,
+				opts:     r.Options,
+				rule:     rule,
+			}
+			ctxs[f] = c
+		}
+		fix := c.fix(terr)
+		switch {
+		case fix == nil:
+			r.logf("%v: cannot fix (%s): %s", pkg.Fset.Position(e.Pos), contextOf(terr), e.Msg)
+			continue
+		case fix.Unfixable != "":
+			r.logf("%v: unfixable: %s", pkg.Fset.Position(fix.Pos), fix.Unfixable)
+		}
+		fixes = append(fixes, fix)
+	}
+	sortFixes(fixes)
+	return fixes, nil
+}
+
+// file returns the file which contains source interval [start, end).
+func (pkg *Package) file(start, end token.Pos) *ast.File {
+	for _, f := range pkg.Files {
+		if f.FileStart <= start && end <= f.FileEnd {
+			return f
+		}
+	}
+	return nil
+}
+
+func sortFixes(fixes []*Fix) {
+	sort.SliceStable(fixes, func(i, j int) bool {
+		if fixes[i].Filename != fixes[j].Filename {
+			return fixes[i].Filename < fixes[j].Filename
+		}
+		return fixes[i].Pos < fixes[j].Pos
+	})
+}
+
+// fix returns the fix of terr or nil if terr cannot be fixed.
+func (c *fileCtx) fix(terr TypeError) *Fix {
+	// Clear the state left by refused fixes.
+	c.pending, c.helpers, c.safety = nil, nil, Lossless
+	c.ctx = contextOf(terr)
+	c.pos = terr.Node().Pos()
+	if !c.opts.enabled(c.ctx) {
+		return nil
+	}
+	switch terr := terr.(type) {
+	case *ErrVarDecl:
+		return rewriteErrVarDecl(c, terr)
+	case *ErrFuncArg:
+		return rewriteErrFuncArg(c, terr)
+	case *ErrAssign:
+		return rewriteErrAssign(c, terr)
+	case *ErrMismatched:
+		return rewriteErrMismatched(c, terr)
+	case *ErrReturn:
+		return rewriteErrReturn(c, terr)
+	case *ErrCompositeLit:
+		return rewriteErrCompositeLit(c, terr)
+	case *ErrSend:
+		return rewriteErrSend(c, terr)
+	case *ErrMultiValue:
+		return rewriteErrMultiValue(c, terr)
+	case *ErrShiftOperand:
+		return rewriteErrShiftOperand(c, terr)
+	case *ErrShiftCount:
+		return rewriteErrShiftCount(c, terr)
+	case *ErrComparison:
+		return rewriteErrComparison(c, terr)
+	case *ErrUnary:
+		return rewriteErrUnary(c, terr)
+	case *ErrIndex:
+		return rewriteErrIndex(c, terr)
+	case *ErrInterface:
+		return rewriteErrInterface(c, terr)
+	}
+	return nil
+}
+
+// newFix creates a fix of node with edits. It takes pending imports, helpers
+// and safety class of the file.
+func (c *fileCtx) newFix(terr TypeError, node ast.Node, rule string, edits []Edit) *Fix {
+	fix := &Fix{
+		Filename: c.filename,
+		Pos:      node.Pos(),
+		End:      node.End(),
+		Err:      terr,
+		Rule:     rule,
+		Edits:    edits,
+		Imports:  c.pending,
+		Helpers:  c.helpers,
+		Safety:   c.safety,
+	}
+	c.pending, c.helpers, c.safety = nil, nil, Lossless
+	tf := c.fset.File(fix.Pos)
+	start, end := tf.Offset(fix.Pos), tf.Offset(fix.End)
+	fix.Original = string(c.src[start:end])
+	replacement, err := applyEdits(c.src[start:end], start, fix.offsetEdits(tf))
+	if err != nil {
+		return nil
+	}
+	fix.Replacement = string(replacement)
+	return fix
+}
+
+// convertFix returns the fix which converts x from type "from" to type "to"
+// or nil if it's not convertible.
+func (c *fileCtx) convertFix(terr TypeError, x ast.Expr, from, to types.Type) *Fix {
+	if c.convertible(from, to) {
+		return c.wrapFix(terr, x, from, to)
+	}
+	return c.helperFix(terr, x, from, to)
+}
+
+// wrapFix creates a fix which converts x from type "from" to type "to" by
+// wrapping x with conversion.
+func (c *fileCtx) wrapFix(terr TypeError, x ast.Expr, from, to types.Type) *Fix {
+	if _, ok := c.template(from, to); !ok {
+		if tv, ok := c.info.Types[x]; ok && tv.Value != nil {
+			if fix, ok := c.constFix(terr, x, tv.Value, from, to); ok {
+				return fix
+			}
+		}
+	}
+	cv, ok := c.conversion(from, to)
+	if !ok {
+		return nil
+	}
+	if neg, ok := ast.Unparen(x).(*ast.UnaryExpr); ok && neg.Op == token.SUB && isUnsigned(from) && isInteger(to) && !isUnsigned(to) {
+		// T(-u) differs from -T(u) if T is wider than u (e.g. int64(-u32)).
+		return c.newFix(terr, x, c.ruleString(from, to), cv.edits(neg.X, true))
+	}
+	return c.newFix(terr, x, c.ruleString(from, to), cv.edits(x, c.operand(x)))
+}
+
+// operandFix creates a fix which converts operand x of binary expression
+// from type "from" to type "to". It unwraps needless conversion call instead
+// if possible.
+func (c *fileCtx) operandFix(terr TypeError, x ast.Expr, from, to types.Type) *Fix {
+	if types.Identical(from, to) {
+		return &Fix{}
+	}
+	if call, ok := unwrappableConversion(x, c.info, from, to); ok {
+		return c.unwrapFix(terr, call, from, to, true)
+	}
+	return c.wrapFix(terr, x, from, to)
+}
+
+// mergeFixes merges fixes of the parts of node into a fix of node. It returns
+// nil if any of fixes is nil, or the fix if it's unfixable.
+func (c *fileCtx) mergeFixes(terr TypeError, node ast.Node, fixes ...*Fix) *Fix {
+	var edits []Edit
+	var rules []string
+	for _, fix := range fixes {
+		if fix == nil {
+			c.pending, c.helpers, c.safety = nil, nil, Lossless
+			return nil
+		}
+		if fix.Unfixable != "" {
+			return fix
+		}
+		if len(fix.Edits) == 0 {
+			continue
+		}
+		edits = append(edits, fix.Edits...)
+		rules = append(rules, fix.Rule)
+		c.pending = append(c.pending, fix.Imports...)
+		c.helpers = append(c.helpers, fix.Helpers...)
+		if fix.Safety > c.safety {
+			c.safety = fix.Safety
+		}
+	}
+	return c.newFix(terr, node, strings.Join(rules, ", "), edits)
+}
+
+// unwrapFix creates a fix which removes needless conversion call to type
+// "from" whose operand is already type "to". If the call is an operand of
+// binary expression, it keeps parentheses of operand as needed.
+func (c *fileCtx) unwrapFix(terr TypeError, call *ast.CallExpr, from, to types.Type, operand bool) *Fix {
+	arg := call.Args[0]
+	var edits []Edit
+	if _, ok := arg.(*ast.BinaryExpr); ok && operand {
+		edits = []Edit{{Pos: call.Fun.Pos(), End: call.Fun.End()}}
+	} else {
+		edits = []Edit{
+			{Pos: call.Pos(), End: arg.Pos()},
+			{Pos: arg.End(), End: call.End()},
+		}
+	}
+	return c.newFix(terr, call, c.ruleString(from, to), edits)
+}
+
+// offsetEdit is Edit in byte offsets of the file.
+type offsetEdit struct {
+	start, end int
+	text       string
+	// order breaks ties of edits at the same position.
+	order int
+}
+
+// offsetEdits returns the edits of the fix in byte offsets of the file tf.
+//
+// Edits at the start of the fix are ordered from outer fixes to inner ones
+// and edits at the end of the fix are ordered from inner fixes to outer ones,
+// so that nested fixes (e.g. T(U(x))) are applied correctly.
+func (fix *Fix) offsetEdits(tf *token.File) []offsetEdit {
+	width := int(fix.End - fix.Pos)
+	edits := make([]offsetEdit, 0, len(fix.Edits))
+	for _, e := range fix.Edits {
+		order := -width
+		if e.Pos == fix.End {
+			order = width - (1 << 30)
+		}
+		edits = append(edits, offsetEdit{
+			start: tf.Offset(e.Pos),
+			end:   tf.Offset(e.End),
+			text:  e.NewText,
+			order: order,
+		})
+	}
+	return edits
+}
+
+// applyEdits applies edits to src which starts at offset base of the file.
+func applyEdits(src []byte, base int, edits []offsetEdit) ([]byte, error) {
+	sort.SliceStable(edits, func(i, j int) bool {
+		ei, ej := edits[i], edits[j]
+		if ei.start != ej.start {
+			return ei.start < ej.start
+		}
+		// Insertions first.
+		if wi, wj := ei.end-ei.start, ej.end-ej.start; (wi == 0) != (wj == 0) {
+			return wi == 0
+		}
+		return ei.order < ej.order
+	})
+	var buf bytes.Buffer
+	cur := base
+	for _, e := range edits {
+		if e.start < cur || e.end > base+len(src) {
+			return nil, fmt.Errorf("invalid edit: [%d, %d)", e.start, e.end)
+		}
+		buf.Write(src[cur-base : e.start-base])
+		buf.WriteString(e.text)
+		cur = e.end
+	}
+	buf.Write(src[cur-base:])
+	return buf.Bytes(), nil
+}
+
+// ApplyFixes applies fixes to src, which is the content of the file fixes
+// belong to, and returns the formatted result. A fix whose edits overlap with
+// edits of preceding fixes or duplicate them is skipped.
+func ApplyFixes(fset *token.FileSet, src []byte, fixes []*Fix) ([]byte, error) {
+	fixes = append([]*Fix(nil), fixes...)
+	// Outer fixes first.
+	sort.SliceStable(fixes, func(i, j int) bool {
+		if fixes[i].Pos != fixes[j].Pos {
+			return fixes[i].Pos < fixes[j].Pos
+		}
+		return fixes[i].End > fixes[j].End
+	})
+	var (
+		edits   []offsetEdit
+		imports []Import
+	)
+	for _, fix := range fixes {
+		tf := fset.File(fix.Pos)
+		if tf == nil {
+			return nil, fmt.Errorf("cannot find file of fix: %s", fix.Filename)
+		}
+		es := fix.offsetEdits(tf)
+		if conflictEdits(edits, es) {
+			continue
+		}
+		edits = append(edits, es...)
+		imports = append(imports, fix.Imports...)
+	}
+	res, err := applyEdits(src, 0, edits)
+	if err != nil {
+		return nil, err
+	}
+	if len(imports) == 0 {
+		return format.Source(res)
+	}
+	fset = token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", res, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	for _, imp := range imports {
+		astutil.AddNamedImport(fset, f, imp.Name, imp.Path)
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// conflictEdits reports whether any edit in es overlaps with edits or is
+// same as one of edits, which means the fix of es is duplicated.
+func conflictEdits(edits, es []offsetEdit) bool {
+	for _, e := range es {
+		for _, x := range edits {
+			if e.start == x.start && e.end == x.end && e.text == x.text {
+				return true
+			}
+			if e.start < x.end && x.start < e.end {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ImportEdits returns the text edits which add imports to file f.
+func ImportEdits(f *ast.File, imports []Import) []Edit {
+	if len(imports) == 0 {
+		return nil
+	}
+	specs := make([]string, 0, len(imports))
+	for _, imp := range imports {
+		spec := strconv.Quote(imp.Path)
+		if imp.Name != "" {
+			spec = imp.Name + " " + spec
+		}
+		specs = append(specs, spec)
+	}
+	var last *ast.GenDecl
+	for _, decl := range f.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.IMPORT {
+			break
+		}
+		last = decl
+	}
+	switch {
+	case last == nil:
+		text := "\n\nimport " + specs[0]
+		if len(specs) > 1 {
+			text = "\n\nimport (\n\t" + strings.Join(specs, "\n\t") + "\n)"
+		}
+		return []Edit{{Pos: f.Name.End(), End: f.Name.End(), NewText: text}}
+	case last.Lparen.IsValid():
+		pos := last.Lparen + 1
+		if len(last.Specs) > 0 {
+			pos = last.Specs[len(last.Specs)-1].End()
+		}
+		text := "\n\t" + strings.Join(specs, "\n\t")
+		return []Edit{{Pos: pos, End: pos, NewText: text}}
+	default:
+		// import "fmt" -> import ("fmt"; ...)
+		spec := last.Specs[0]
+		return []Edit{
+			{Pos: spec.Pos(), End: spec.Pos(), NewText: "(\n\t"},
+			{Pos: spec.End(), End: spec.End(), NewText: "\n\t" + strings.Join(specs, "\n\t") + "\n)"},
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package typeconv
+
+import (
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// loadFixes loads the packages of pattern and returns the fixes of their type
+// errors by rw.
+func loadFixes(t *testing.T, pattern string, rw *Rewriter) []*Fix {
+	t.Helper()
+	prog, typeErrs, err := Load(nil, pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixes, err := rw.Fixes(prog, typeErrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fixes
+}
+
+// replacements returns the replacements of fixes.
+func replacements(fixes []*Fix) []string {
+	var rs []string
+	for _, fix := range fixes {
+		rs = append(rs, fix.Replacement)
+	}
+	return rs
+}
+
+func TestFixes(t *testing.T) {
+	prog, typeErrs, err := Load(nil, "testdata/max.input.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixes, err := Fixes(prog, typeErrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		original    string
+		replacement string
+		rule        string
+	}{
+		{"max(x, x+y, z)", "int(max(x, x+y, z))", "int64 -> int"},
+		{"x", "int64(x)", "int -> int64"},
+		{"x", "int64(x)", "int -> int64"},
+		{"z", "int64(z)", "float64 -> int64"},
+	}
+	if len(fixes) != len(want) {
+		t.Fatalf("len(fixes) == %d, want %d", len(fixes), len(want))
+	}
+	for i, fix := range fixes {
+		if fix.Original != want[i].original {
+			t.Errorf("fixes[%d].Original == %q, want %q", i, fix.Original, want[i].original)
+		}
+		if fix.Replacement != want[i].replacement {
+			t.Errorf("fixes[%d].Replacement == %q, want %q", i, fix.Replacement, want[i].replacement)
+		}
+		if fix.Rule != want[i].rule {
+			t.Errorf("fixes[%d].Rule == %q, want %q", i, fix.Rule, want[i].rule)
+		}
+		if fix.Err == nil {
+			t.Errorf("fixes[%d].Err is nil", i)
+		}
+	}
+}
+
+func TestApplyFixes_selected(t *testing.T) {
+	prog, typeErrs, err := Load(nil, "testdata/max.input.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixes, err := Fixes(prog, typeErrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var selected []*Fix
+	for _, fix := range fixes {
+		if fix.Rule == "int -> int64" {
+			selected = append(selected, fix)
+		}
+	}
+	src, err := prog.ReadFile(fixes[0].Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := ApplyFixes(prog.Fset, src, selected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "var ans int = max(int64(x), int64(x)+y, z)"
+	if !strings.Contains(string(res), want) {
+		t.Errorf("ApplyFixes: got\n%s\nwant it contains %q", res, want)
+	}
+}
+
+func TestApplyFixes_imports(t *testing.T) {
+	prog, typeErrs, err := Load(nil, "testdata/addimport.input.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixes, err := Fixes(prog, typeErrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixes) != 1 {
+		t.Fatalf("len(fixes) == %d, want 1", len(fixes))
+	}
+	if got := fixes[0].Imports; len(got) != 1 || got[0] != (Import{Path: "time"}) {
+		t.Errorf("fixes[0].Imports == %v, want [{ time}]", got)
+	}
+}
+
+func TestFixes_lossy(t *testing.T) {
+	tests := []struct {
+		lossy LossyPolicy
+		want  []string
+	}{
+		{LossyAllow, []string{"uint(i)", "int(f)", "int32(i)", "int64(i32)"}},
+		{LossyRefuse, []string{"int64(i32)"}},
+		{LossyCheck, []string{"convertChecked[uint](i)", "convertChecked[int](f)", "convertChecked[int32](i)", "int64(i32)"}},
+	}
+	wantSafety := map[string]Safety{
+		"int -> uint":    SignChanging,
+		"float64 -> int": FloatToInt,
+		"int -> int32":   Truncating,
+		"int32 -> int64": Lossless,
+	}
+	for _, tt := range tests {
+		fixes := loadFixes(t, "testdata/lossy.input.go", NewRewriter(Options{Lossy: tt.lossy}))
+		for _, fix := range fixes {
+			if s := wantSafety[fix.Rule]; fix.Safety != s {
+				t.Errorf("lossy=%d: %s: Safety == %v, want %v", tt.lossy, fix.Replacement, fix.Safety, s)
+			}
+			if wantHelper := strings.HasPrefix(fix.Replacement, helperChecked); wantHelper != (len(fix.Helpers) == 1) {
+				t.Errorf("lossy=%d: %s: Helpers == %v", tt.lossy, fix.Replacement, fix.Helpers)
+			}
+		}
+		if got := replacements(fixes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lossy=%d: replacements == %q, want %q", tt.lossy, got, tt.want)
+		}
+	}
+}
+
+func TestFixes_constantOverflow(t *testing.T) {
+	var unfixable []string
+	for _, fix := range loadFixes(t, "testdata/constant.input.go", &Rewriter{}) {
+		if fix.Unfixable == "" {
+			continue
+		}
+		unfixable = append(unfixable, fix.Unfixable)
+		if len(fix.Edits) != 0 || fix.Replacement != fix.Original {
+			t.Errorf("unfixable fix has edits: %v", fix.Edits)
+		}
+	}
+	// Typed constant int(-1) and untyped constants -1 and 300.
+	want := []string{
+		"constant -1 overflows uint",
+		"constant -1 overflows uint",
+		"constant 300 overflows int8",
+	}
+	if !reflect.DeepEqual(unfixable, want) {
+		t.Errorf("Unfixable == %q, want %q", unfixable, want)
+	}
+}
+
+func TestFixes_rule(t *testing.T) {
+	prog, typeErrs, err := Load(nil, "testdata/max.input.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := &Rule{}
+	rule.Add("int", "int64")
+	rule.Deny("int", "int64", ContextFuncArg)
+	rule.Package(prog.Packages[0].PkgPath).Deny("float64", "int64")
+	fixes, err := (&Rewriter{Rule: rule}).Fixes(prog, typeErrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// max(x, ...) and max(..., z) are denied.
+	want := []string{"int(max(x, x+y, z))", "int64(x)"}
+	if got := replacements(fixes); !reflect.DeepEqual(got, want) {
+		t.Errorf("replacements == %q, want %q", got, want)
+	}
+	if len(fixes) == 2 && fixes[1].Pos != fixes[0].Pos+token.Pos(len("max(x, ")) {
+		t.Errorf("fixes[1] should fix x of x+y: %v", prog.Fset.Position(fixes[1].Pos))
+	}
+}
+
+func TestFixes_patterns(t *testing.T) {
+	rule := &Rule{}
+	rule.Add("underlying(int)", "int")
+	rule.Add("signed", "~unsigned")
+	fixes := loadFixes(t, "./testdata/pattern", &Rewriter{Rule: rule})
+	want := []string{"int(id)", "uint(i8)"}
+	if got := replacements(fixes); !reflect.DeepEqual(got, want) {
+		t.Errorf("replacements == %q, want %q", got, want)
+	}
+}
+
+func TestFixes_chain(t *testing.T) {
+	prog, typeErrs, err := Load(nil, "./testdata/chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := &Rule{}
+	rule.Add("int8", "int16")
+	rule.Add("int16", prog.Packages[0].PkgPath+".MyInt")
+	rule.Add("uint8", "uint16")
+	rule.Add("uint16", "int32")
+	rule.Add("~int32", "float32")
+	fixes, err := (&Rewriter{Rule: rule}).Fixes(prog, typeErrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// int32 -> MyInt is not added, so u + m cannot be fixed.
+	want := []string{
+		"MyInt(i8) (int8 -> int16 -> MyInt)",
+		"float32(m) (MyInt -> float32)",
+	}
+	var got []string
+	for _, fix := range fixes {
+		got = append(got, fix.Replacement+" ("+fix.Rule+")")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fixes == %q, want %q", got, want)
+	}
+}
+
+func TestFixes_commonType(t *testing.T) {
+	rule := &Rule{}
+	rule.Add("uint8", "int16")
+	rule.Add("~int32", "int16")
+	rule.Add("int8", "int16")
+	fixes := loadFixes(t, "./testdata/chain", &Rewriter{Rule: rule})
+	// Neither operand can be converted to the other, so both are converted
+	// to int16.
+	want := []string{
+		"int16(i8) + int16(m)",
+		"int16(u) + int16(m)",
+	}
+	if got := replacements(fixes); !reflect.DeepEqual(got, want) {
+		t.Errorf("replacements == %q, want %q", got, want)
+	}
+	if len(fixes) > 0 && fixes[0].Rule != "int8 -> int16, MyInt -> int16" {
+		t.Errorf("Rule == %q", fixes[0].Rule)
+	}
+}
+
+func TestFixes_indexLossy(t *testing.T) {
+	fixes := loadFixes(t, "testdata/index.input.go", NewRewriter(Options{Lossy: LossyRefuse}))
+	// Float indices and uint64 -> int64 are lossy, and constant 1.5 is
+	// unfixable.
+	want := []string{"int64(i32)", "1", "1.5"}
+	if got := replacements(fixes); !reflect.DeepEqual(got, want) {
+		t.Errorf("replacements == %q, want %q", got, want)
+	}
+}
+
+func TestFixes_interface(t *testing.T) {
+	var unfixable []string
+	for _, fix := range loadFixes(t, "testdata/iface.input.go", &Rewriter{}) {
+		if _, ok := fix.Err.(*ErrInterface); !ok {
+			t.Errorf("%s: Err == %T, want *ErrInterface", fix.Original, fix.Err)
+		}
+		if fix.Unfixable != "" {
+			unfixable = append(unfixable, fix.Unfixable)
+		}
+	}
+	want := []string{
+		`*Buffer implements io.Writer but m["a"] is not addressable`,
+		`*Buffer implements io.Writer but newBuffer() is not addressable`,
+	}
+	if !reflect.DeepEqual(unfixable, want) {
+		t.Errorf("Unfixable == %q, want %q", unfixable, want)
+	}
+}
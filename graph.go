@@ -0,0 +1,132 @@
+package typeconv
+
+import (
+	"go/types"
+	"strings"
+)
+
+// Path returns the cheapest chain of conversions from "from" to "to" by the
+// rules added by Add in the package of import path pkgPath, including both
+// ends (e.g. [int8 int16 MyInt] for rules int8 -> int16 and int16 -> MyInt).
+// It reports false if there is no chain.
+//
+// The rules form a graph whose nodes are the types and the cost of each
+// conversion is derived from its priority (1 - priority, so the first rule
+// costs 1). The priority of the chain is 1 - the sum of the costs, which is
+// the priority of the rule itself for a direct conversion. Chains of
+// multiple conversions go through lossless conversions only (see Classify).
+//
+// Intermediate types are the types written in the rules without patterns
+// (e.g. int16, example.com/p.MyInt). Named types are resolved from the
+// packages of "from" and "to" and their imports.
+func (r *Rule) Path(pkgPath string, from, to types.Type) (path []types.Type, priority int, ok bool) {
+	nodes := r.nodes(pkgPath, from, to)
+	const inf = int(^uint(0) >> 1)
+	// Dijkstra's algorithm. The graph is small, so it doesn't use heap.
+	dist := make([]int, len(nodes))
+	prev := make([]int, len(nodes))
+	done := make([]bool, len(nodes))
+	for i := range dist {
+		dist[i], prev[i] = inf, -1
+	}
+	dist[0] = 0
+	for {
+		u := -1
+		for i := range nodes {
+			if !done[i] && dist[i] < inf && (u < 0 || dist[i] < dist[u]) {
+				u = i
+			}
+		}
+		if u < 0 || u == 1 {
+			break
+		}
+		done[u] = true
+		for v := range nodes {
+			if done[v] || v == u {
+				continue
+			}
+			p, ok := r.direct(pkgPath, nodes[u], nodes[v])
+			if !ok {
+				continue
+			}
+			// Chains must not lose information.
+			if (u != 0 || v != 1) && Classify(nodes[u], nodes[v]) != Lossless {
+				continue
+			}
+			if d := dist[u] + 1 - p; d < dist[v] {
+				dist[v], prev[v] = d, u
+			}
+		}
+	}
+	if dist[1] == inf {
+		return nil, 0, false
+	}
+	for i := 1; i >= 0; i = prev[i] {
+		path = append([]types.Type{nodes[i]}, path...)
+	}
+	return path, 1 - dist[1], true
+}
+
+// direct returns the priority of the rule which converts "from" to "to"
+// directly.
+func (r *Rule) direct(pkgPath string, from, to types.Type) (priority int, ok bool) {
+	for _, rule := range r.chain(pkgPath) {
+		if priority, ok := rule.ConvertibleTo(from, to); ok {
+			return priority, true
+		}
+	}
+	return 0, false
+}
+
+// nodes returns the nodes of the conversion graph. The first and second
+// nodes are "from" and "to".
+func (r *Rule) nodes(pkgPath string, from, to types.Type) []types.Type {
+	nodes := []types.Type{from, to}
+	seen := map[string]bool{from.String(): true, to.String(): true}
+	for _, rule := range r.chain(pkgPath) {
+		for _, c := range rule.rules {
+			for _, p := range []pattern{c.from, c.to} {
+				name, ok := p.(typePattern)
+				if !ok || seen[string(name)] {
+					continue
+				}
+				seen[string(name)] = true
+				if t := resolveType(string(name), from, to); t != nil {
+					nodes = append(nodes, t)
+				}
+			}
+		}
+	}
+	return nodes
+}
+
+// resolveType returns the type of name (e.g. int, example.com/p.MyInt) or
+// nil if it's not found. Named types are looked up in the packages of known
+// types and their imports.
+func resolveType(name string, known ...types.Type) types.Type {
+	if obj, ok := types.Universe.Lookup(name).(*types.TypeName); ok {
+		return obj.Type()
+	}
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return nil
+	}
+	path, typeName := name[:i], name[i+1:]
+	for _, t := range known {
+		named, ok := t.(*types.Named)
+		if !ok || named.Obj().Pkg() == nil {
+			continue
+		}
+		pkg := named.Obj().Pkg()
+		if pkg.Path() != path {
+			var err error
+			if pkg, err = importPackage(pkg, path); err != nil {
+				continue
+			}
+		}
+		if obj, ok := pkg.Scope().Lookup(typeName).(*types.TypeName); ok {
+			return obj.Type()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package typeconv
+
+import (
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestRule_Path(t *testing.T) {
+	pkg := types.NewPackage("example.com/p", "p")
+	myInt := types.NewNamed(types.NewTypeName(0, pkg, "MyInt", nil), typ("int32"), nil)
+	pkg.Scope().Insert(myInt.Obj())
+
+	r := &Rule{}
+	r.Add("int8", "int16")                // 0: cost 1
+	r.Add("int16", "example.com/p.MyInt") // -1: cost 2
+	r.Add("int8", "int64")                // -2: cost 3
+	r.Add("int64", "float64")             // -3: truncating
+	r.Add("uint8", "example.com/p.MyInt") // -4: cost 5
+	r.Add("uint8", "int16")               // -5: cost 6
+	r.Add("float64", "float32")           // -6: direct only
+
+	tests := []struct {
+		from, to types.Type
+		want     string
+		priority int
+	}{
+		{typ("int8"), myInt, "int8 -> int16 -> example.com/p.MyInt", -2},
+		{typ("int8"), typ("int16"), "int8 -> int16", 0},
+		// int8 -> int64 -> float64 loses information.
+		{typ("int8"), typ("float64"), "", 0},
+		// The direct rule is cheaper than uint8 -> int16 -> MyInt.
+		{typ("uint8"), myInt, "uint8 -> example.com/p.MyInt", -4},
+		{typ("float64"), typ("float32"), "float64 -> float32", -6},
+		{myInt, typ("int8"), "", 0},
+	}
+	for _, tt := range tests {
+		path, priority, ok := r.Path("", tt.from, tt.to)
+		var s []string
+		for _, t := range path {
+			s = append(s, t.String())
+		}
+		if got := strings.Join(s, " -> "); got != tt.want || ok != (tt.want != "") || priority != tt.priority {
+			t.Errorf("Path(%v, %v) == (%q, %d, %v), want (%q, %d)", tt.from, tt.to, got, priority, ok, tt.want, tt.priority)
+		}
+	}
+}
+
+func TestResolveType(t *testing.T) {
+	dep := types.NewPackage("example.com/dep", "dep")
+	id := types.NewNamed(types.NewTypeName(0, dep, "ID", nil), typ("int"), nil)
+	dep.Scope().Insert(id.Obj())
+	pkg := types.NewPackage("example.com/p", "p")
+	pkg.SetImports([]*types.Package{dep})
+	myInt := types.NewNamed(types.NewTypeName(0, pkg, "MyInt", nil), typ("int"), nil)
+	pkg.Scope().Insert(myInt.Obj())
+
+	for name, want := range map[string]types.Type{
+		"int":                 typ("int"),
+		"example.com/p.MyInt": myInt,
+		"example.com/dep.ID":  id,
+		"example.com/dep.X":   nil,
+		"example.com/q.Y":     nil,
+		"[]int":               nil,
+	} {
+		if got := resolveType(name, myInt); got != want {
+			t.Errorf("resolveType(%q) == %v, want %v", name, got, want)
+		}
+	}
+}
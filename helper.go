@@ -0,0 +1,243 @@
+package typeconv
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// HelperFilename is the name of the file which holds the generated
+// conversion helper functions of a package.
+const HelperFilename = "typeconv_helpers.go"
+
+const (
+	helperSlice = "convertSlice"
+	helperMap   = "convertMap"
+
+	helperChecked = "convertChecked"
+)
+
+// numberConstraint is the constraint of element types which helper functions
+// can convert.
+const numberConstraint = "~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr | ~float32 | ~float64"
+
+// helperDecls holds the declarations of helper functions by name.
+var helperDecls = map[string]string{
+	helperSlice: `// convertSlice returns a new slice whose elements are converted from xs.
+func convertSlice[U, T ` + numberConstraint + `](xs []T) []U {
+	if xs == nil {
+		return nil
+	}
+	ys := make([]U, len(xs))
+	for i, x := range xs {
+		ys[i] = U(x)
+	}
+	return ys
+}
+`,
+	helperMap: `// convertMap returns a new map whose values are converted from m.
+func convertMap[U, T ` + numberConstraint + `, K comparable](m map[K]T) map[K]U {
+	if m == nil {
+		return nil
+	}
+	n := make(map[K]U, len(m))
+	for k, v := range m {
+		n[k] = U(v)
+	}
+	return n
+}
+`,
+	helperChecked: `// convertChecked converts x to U. It panics if the value cannot be
+// represented in U exactly.
+func convertChecked[U, T ` + numberConstraint + `](x T) U {
+	u := U(x)
+	if T(u) != x || (u < 0) != (x < 0) {
+		panic("typeconv: value is not representable in the converted type")
+	}
+	return u
+}
+`,
+}
+
+// helperFix returns the fix which converts x from type "from" to type "to"
+// by a helper function if both are slices or maps and their element types
+// are convertible by the rule. Pointers to numbers are refused since a
+// pointer to the converted copy doesn't alias the original value.
+//
+//	var ys []int64 = xs
+//
+// is rewritten to
+//
+//	var ys []int64 = convertSlice[int64](xs)
+func (c *fileCtx) helperFix(terr TypeError, x ast.Expr, from, to types.Type) *Fix {
+	if f, ok := from.Underlying().(*types.Pointer); ok {
+		if t, ok := to.Underlying().(*types.Pointer); ok && isNumber(f.Elem()) && isNumber(t.Elem()) {
+			fix := c.newFix(terr, x, "", nil)
+			if fix != nil {
+				fix.Unfixable = fmt.Sprintf("cannot convert %s from %s to %s since a pointer to the converted copy doesn't alias %s", types.ExprString(x), c.typeString(from), c.typeString(to), types.ExprString(x))
+			}
+			return fix
+		}
+	}
+	name, elemFrom, elemTo, ok := helperFunc(from, to)
+	if !ok || !isNumber(elemFrom) || !isNumber(elemTo) {
+		return nil
+	}
+	// Element conversions must be added to the rule as well as conversions
+	// in binary expressions.
+	if _, ok := c.rule.Priority(c.pkg.Path(), elemFrom, elemTo); !ok {
+		return nil
+	}
+	if !c.allowed(from, to) || !c.allowed(elemFrom, elemTo) {
+		return nil
+	}
+	// Helper functions don't check each element.
+	if s := Classify(elemFrom, elemTo); s != Lossless {
+		if c.opts.Lossy != LossyAllow {
+			return nil
+		}
+		c.safety = s
+	}
+	if !c.requireHelper(name) {
+		return nil
+	}
+	return c.newFix(terr, x, c.ruleString(from, to), []Edit{
+		{Pos: x.Pos(), End: x.Pos(), NewText: fmt.Sprintf("%s[%s](", name, c.typeString(elemTo))},
+		{Pos: x.End(), End: x.End(), NewText: ")"},
+	})
+}
+
+// requireHelper records the helper function as required by the fix being
+// created unless the package already has it. It reports false if the helper
+// cannot be used in the package.
+func (c *fileCtx) requireHelper(name string) bool {
+	// External test package cannot have its own helper file in the same
+	// directory.
+	if strings.HasSuffix(c.pkg.Name(), "_test") {
+		return false
+	}
+	if obj := c.pkg.Scope().Lookup(name); obj != nil {
+		// Reuse the helper generated before.
+		_, ok := obj.(*types.Func)
+		return ok && filepath.Base(c.fset.Position(obj.Pos()).Filename) == HelperFilename
+	}
+	for _, h := range c.helpers {
+		if h == name {
+			return true
+		}
+	}
+	c.helpers = append(c.helpers, name)
+	return true
+}
+
+// helperFunc returns the name of helper function which converts type "from"
+// to type "to" along with their element types.
+func helperFunc(from, to types.Type) (name string, elemFrom, elemTo types.Type, ok bool) {
+	switch f := from.Underlying().(type) {
+	case *types.Slice:
+		if t, ok := to.Underlying().(*types.Slice); ok {
+			return helperSlice, f.Elem(), t.Elem(), true
+		}
+	case *types.Map:
+		if t, ok := to.Underlying().(*types.Map); ok && types.Identical(f.Key(), t.Key()) {
+			return helperMap, f.Elem(), t.Elem(), true
+		}
+	}
+	return "", nil, nil, false
+}
+
+// isNumber reports whether t satisfies numberConstraint.
+func isNumber(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&(types.IsInteger|types.IsFloat) != 0 && b.Info()&types.IsUntyped == 0
+}
+
+// HelperFiles returns the contents of HelperFilename by filename for the
+// packages of prog which fixes require helper functions for.
+func HelperFiles(prog *Program, fixes []*Fix) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, pkg := range prog.Packages {
+		filename, src, err := prog.helperFile(pkg, fixes)
+		if err != nil {
+			return nil, err
+		}
+		if src != nil {
+			files[filename] = src
+		}
+	}
+	return files, nil
+}
+
+// helperFile returns the filename and the content of HelperFilename of pkg
+// if fixes require helper functions for pkg. Otherwise, it returns nil
+// content.
+func (prog *Program) helperFile(pkg *packages.Package, fixes []*Fix) (string, []byte, error) {
+	files := make(map[string]bool)
+	for _, f := range pkg.Syntax {
+		files[prog.Fset.File(f.Pos()).Name()] = true
+	}
+	var helpers []string
+	var dir string
+	for _, fix := range fixes {
+		if files[fix.Filename] && len(fix.Helpers) > 0 {
+			helpers = append(helpers, fix.Helpers...)
+			dir = filepath.Dir(fix.Filename)
+		}
+	}
+	if len(helpers) == 0 {
+		return "", nil, nil
+	}
+	filename := filepath.Join(dir, HelperFilename)
+	src, err := prog.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return "", nil, err
+	}
+	res, err := GenerateHelpers(src, pkg.Types.Name(), helpers)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return filename, res, nil
+}
+
+// GenerateHelpers adds the declarations of helper functions to src, which is
+// the content of HelperFilename of the package or empty, and returns the
+// formatted result. Helper functions which src already has are skipped.
+func GenerateHelpers(src []byte, pkgName string, helpers []string) ([]byte, error) {
+	declared := make(map[string]bool)
+	var buf bytes.Buffer
+	if len(bytes.TrimSpace(src)) == 0 {
+		fmt.Fprintf(&buf, "// Code generated by gotypeconv. DO NOT EDIT.\n\npackage %s\n", pkgName)
+	} else {
+		f, err := parser.ParseFile(token.NewFileSet(), HelperFilename, src, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range f.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok {
+				declared[fd.Name.Name] = true
+			}
+		}
+		buf.Write(src)
+	}
+	for _, name := range helpers {
+		decl, ok := helperDecls[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown helper function: %s", name)
+		}
+		if declared[name] {
+			continue
+		}
+		declared[name] = true
+		buf.WriteString("\n" + decl)
+	}
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,34 @@
+package typeconv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateHelpers(t *testing.T) {
+	src, err := GenerateHelpers(nil, "p", []string{helperSlice, helperSlice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(src), "// Code generated by gotypeconv. DO NOT EDIT.\n\npackage p\n") {
+		t.Errorf("GenerateHelpers: got\n%s\nwant generated file of package p", src)
+	}
+	if n := strings.Count(string(src), "func convertSlice["); n != 1 {
+		t.Errorf("GenerateHelpers: got %d convertSlice, want 1", n)
+	}
+
+	// Add a helper to the existing file.
+	src, err = GenerateHelpers(src, "p", []string{helperMap, helperSlice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{helperSlice, helperMap} {
+		if n := strings.Count(string(src), "func "+name+"["); n != 1 {
+			t.Errorf("GenerateHelpers: got %d %s, want 1", n, name)
+		}
+	}
+
+	if _, err := GenerateHelpers(nil, "p", []string{"unknown"}); err == nil {
+		t.Error("GenerateHelpers: want error for unknown helper")
+	}
+}
@@ -0,0 +1,141 @@
+package typeconv
+
+import (
+	"fmt"
+	"go/parser"
+	"go/types"
+	"regexp"
+	"strings"
+)
+
+// pattern matches types in conversion rules.
+//
+// The syntax of patterns is
+//
+//	int, []int, time.Duration  the type itself
+//	signed, unsigned, integer, float, complex, numeric
+//	                            the class of basic types
+//	*int*, int?                 types whose string matches the wildcards ("*"
+//	                            matches any string and "?" any character)
+//	~P                          types which match P or whose underlying type
+//	                            matches P (e.g. ~int matches int and MyInt)
+//	underlying(P)               named types whose underlying type matches P
+//
+// Types of other packages are written with their import paths (e.g.
+// github.com/foo/bar.ID) as types.Type.String.
+type pattern interface {
+	match(t types.Type) bool
+}
+
+// classes holds the classes of basic types by the properties of types.
+var classes = map[string]func(info types.BasicInfo) bool{
+	"signed":   func(info types.BasicInfo) bool { return info&types.IsInteger != 0 && info&types.IsUnsigned == 0 },
+	"unsigned": func(info types.BasicInfo) bool { return info&types.IsUnsigned != 0 },
+	"integer":  func(info types.BasicInfo) bool { return info&types.IsInteger != 0 },
+	"float":    func(info types.BasicInfo) bool { return info&types.IsFloat != 0 },
+	"complex":  func(info types.BasicInfo) bool { return info&types.IsComplex != 0 },
+	"numeric":  func(info types.BasicInfo) bool { return info&types.IsNumeric != 0 },
+}
+
+// parsePattern parses the type pattern s.
+func parsePattern(s string) (pattern, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return nil, fmt.Errorf("empty type pattern")
+	case strings.HasPrefix(s, "~"):
+		p, err := parsePattern(s[1:])
+		if err != nil {
+			return nil, err
+		}
+		return tildePattern{p}, nil
+	case strings.HasPrefix(s, "underlying(") && strings.HasSuffix(s, ")"):
+		p, err := parsePattern(s[len("underlying(") : len(s)-1])
+		if err != nil {
+			return nil, err
+		}
+		return underlyingPattern{p}, nil
+	case isWildcard(s):
+		return newGlobPattern(s), nil
+	}
+	if _, ok := classes[s]; ok {
+		return classPattern(s), nil
+	}
+	// Import paths are not valid expressions.
+	if _, err := parser.ParseExpr(s[strings.LastIndex(s, "/")+1:]); err != nil {
+		return nil, fmt.Errorf("invalid type pattern %q", s)
+	}
+	return typePattern(s), nil
+}
+
+// isWildcard reports whether s has wildcards. Leading "*"s are pointers
+// (e.g. *int) unless they are followed by other wildcards (e.g. *int*).
+func isWildcard(s string) bool {
+	t := strings.TrimLeft(s, "*")
+	return t == "" || strings.ContainsAny(t, "*?")
+}
+
+// typePattern matches the type whose string is the pattern.
+type typePattern string
+
+func (p typePattern) match(t types.Type) bool {
+	return t.String() == string(p)
+}
+
+// classPattern matches the basic types of the class.
+type classPattern string
+
+func (p classPattern) match(t types.Type) bool {
+	b, ok := t.(*types.Basic)
+	if !ok || b.Info()&types.IsUntyped != 0 {
+		return false
+	}
+	return classes[string(p)](b.Info())
+}
+
+// globPattern matches the types whose string matches the wildcards.
+type globPattern struct {
+	re *regexp.Regexp
+}
+
+func newGlobPattern(s string) globPattern {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range s {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return globPattern{regexp.MustCompile(b.String())}
+}
+
+func (p globPattern) match(t types.Type) bool {
+	return p.re.MatchString(t.String())
+}
+
+// tildePattern matches the types which match the pattern or whose underlying
+// types match the pattern.
+type tildePattern struct {
+	p pattern
+}
+
+func (p tildePattern) match(t types.Type) bool {
+	return p.p.match(t) || p.p.match(t.Underlying())
+}
+
+// underlyingPattern matches the named types whose underlying types match the
+// pattern.
+type underlyingPattern struct {
+	p pattern
+}
+
+func (p underlyingPattern) match(t types.Type) bool {
+	u := t.Underlying()
+	return !types.Identical(t, u) && p.p.match(u)
+}
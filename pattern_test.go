@@ -0,0 +1,53 @@
+package typeconv
+
+import (
+	"go/types"
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	pkg := types.NewPackage("example.com/p", "p")
+	myInt := types.NewNamed(types.NewTypeName(0, pkg, "MyInt", nil), typ("int"), nil)
+	myUint := types.NewNamed(types.NewTypeName(0, pkg, "MyUint", nil), typ("uint"), nil)
+	intPtr := types.NewPointer(typ("int"))
+	tests := []struct {
+		pattern string
+		types   []types.Type
+		want    []bool
+	}{
+		{"int", []types.Type{typ("int"), myInt, typ("int64")}, []bool{true, false, false}},
+		{"*int", []types.Type{intPtr, typ("int")}, []bool{true, false}},
+		{"example.com/p.MyInt", []types.Type{myInt, typ("int")}, []bool{true, false}},
+		{"signed", []types.Type{typ("int8"), typ("int"), typ("uint"), typ("float64"), myInt, types.Typ[types.UntypedInt]}, []bool{true, true, false, false, false, false}},
+		{"unsigned", []types.Type{typ("uint"), typ("uintptr"), typ("int")}, []bool{true, true, false}},
+		{"integer", []types.Type{typ("int"), typ("uint8"), typ("float32")}, []bool{true, true, false}},
+		{"float", []types.Type{typ("float32"), typ("float64"), typ("int")}, []bool{true, true, false}},
+		{"numeric", []types.Type{typ("complex64"), typ("int"), typ("string")}, []bool{true, true, false}},
+		{"*int*", []types.Type{typ("int"), typ("uint16"), intPtr, myInt, typ("float64")}, []bool{true, true, true, false, false}},
+		{"int?", []types.Type{typ("int8"), typ("int"), typ("int16")}, []bool{true, false, false}},
+		{"~int", []types.Type{typ("int"), myInt, myUint}, []bool{true, true, false}},
+		{"~signed", []types.Type{myInt, myUint}, []bool{true, false}},
+		{"underlying(int)", []types.Type{typ("int"), myInt}, []bool{false, true}},
+		{"underlying(unsigned)", []types.Type{myInt, myUint}, []bool{false, true}},
+	}
+	for _, tt := range tests {
+		p, err := parsePattern(tt.pattern)
+		if err != nil {
+			t.Errorf("parsePattern(%q): %v", tt.pattern, err)
+			continue
+		}
+		for i, typ := range tt.types {
+			if got := p.match(typ); got != tt.want[i] {
+				t.Errorf("%q.match(%v) == %v, want %v", tt.pattern, typ, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestParsePattern_invalid(t *testing.T) {
+	for _, s := range []string{"", "~", "underlying()", "underlying(int", "int)", "[]"} {
+		if _, err := parsePattern(s); err == nil {
+			t.Errorf("parsePattern(%q) succeeded, want error", s)
+		}
+	}
+}
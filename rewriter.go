@@ -0,0 +1,39 @@
+package typeconv
+
+import "log"
+
+// Rewriter fixes type conversion errors with its own rule and options.
+//
+// A Rewriter doesn't modify its rule, so Rewriters with different
+// configurations can be used in the same process and a Rewriter can be used
+// concurrently as long as the programs are different and the rule is not
+// modified meanwhile.
+type Rewriter struct {
+	// Rule is the type conversion rule. A rule created by NewDefaultRule is
+	// used if it's nil.
+	Rule *Rule
+	// Options is the options of fixes.
+	Options Options
+	// Logger logs type errors which are skipped or cannot be fixed. Logs are
+	// discarded if it's nil.
+	Logger *log.Logger
+}
+
+// NewRewriter returns a new Rewriter with the default rule and opts.
+func NewRewriter(opts Options) *Rewriter {
+	return &Rewriter{Rule: NewDefaultRule(), Options: opts}
+}
+
+// rule returns the type conversion rule of r.
+func (r *Rewriter) rule() *Rule {
+	if r.Rule != nil {
+		return r.Rule
+	}
+	return NewDefaultRule()
+}
+
+func (r *Rewriter) logf(format string, args ...interface{}) {
+	if r.Logger != nil {
+		r.Logger.Printf(format, args...)
+	}
+}
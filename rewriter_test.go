@@ -0,0 +1,81 @@
+package typeconv
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRewriter_independent(t *testing.T) {
+	deny := &Rule{}
+	deny.Deny("int", "int64")
+	rewriters := []*Rewriter{
+		NewRewriter(Options{}),
+		{Rule: deny},
+	}
+	want := []string{
+		"int(max(int64(x), int64(x)+y, int64(z)))",
+		"int(max(x, x+y, int64(z)))",
+	}
+	got := make([]string, len(rewriters))
+	errs := make([]error, len(rewriters))
+	var wg sync.WaitGroup
+	for i, rw := range rewriters {
+		wg.Add(1)
+		go func(i int, rw *Rewriter) {
+			defer wg.Done()
+			prog, typeErrs, err := Load(nil, "testdata/max.input.go")
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			fixes, err := rw.Fixes(prog, typeErrs)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			src, err := prog.ReadFile(fixes[0].Filename)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			res, err := ApplyFixes(prog.Fset, src, fixes)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			got[i] = strings.TrimSpace(strings.Split(strings.Split(string(res), "var ans int = ")[1], "\n")[0])
+		}(i, rw)
+	}
+	wg.Wait()
+	for i := range rewriters {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if got[i] != want[i] {
+			t.Errorf("rewriters[%d]: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRewriter_logger(t *testing.T) {
+	prog, typeErrs, err := Load(nil, "testdata/tour.input.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	rw := NewRewriter(Options{Contexts: []Context{ContextFuncArg}})
+	rw.Logger = log.New(&buf, "", 0)
+	fixes, err := rw.Fixes(prog, typeErrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixes) != 1 {
+		t.Errorf("got %d fixes, want 1", len(fixes))
+	}
+	if want := "cannot fix (vardecl)"; !strings.Contains(buf.String(), want) {
+		t.Errorf("log %q doesn't contain %q", buf.String(), want)
+	}
+}
@@ -1,41 +1,240 @@
 package typeconv
 
+import (
+	"fmt"
+	"go/types"
+	"strings"
+)
+
 // Rule represents type conversion rule.
 //
-// from -> to -> priority
+// It holds the priorities of conversions (from -> to -> priority) which are
+// used to choose the type of binary expressions, and the policies which allow
+// or deny conversions per context and per package.
+//
+// Types of conversions are patterns which match types (e.g. int, signed,
+// *int*, ~int or underlying(int)). See parsePattern for the syntax.
 type Rule struct {
 	next  int
-	rules map[string]map[string]int
+	rules []conversion
+
+	policies  []policy
+	templates []template
+	// packages holds the rules specific to packages by import path.
+	packages map[string]*Rule
 }
 
-// Add adds type conversion rule.
-func (r *Rule) Add(from, to string) {
-	if len(r.rules) == 0 {
-		r.rules = make(map[string]map[string]int)
+// ParseConversion parses type conversion of the form "from -> to" and
+// validates the type patterns.
+func ParseConversion(s string) (from, to string, err error) {
+	f := strings.Split(s, "->")
+	if len(f) != 2 {
+		return "", "", fmt.Errorf("type conversion must be the form 'from -> to': %q", s)
+	}
+	from, to = strings.TrimSpace(f[0]), strings.TrimSpace(f[1])
+	if from == "" || to == "" {
+		return "", "", fmt.Errorf("type conversion must be the form 'from -> to': %q", s)
+	}
+	for _, p := range []string{from, to} {
+		if _, err := parsePattern(p); err != nil {
+			return "", "", err
+		}
 	}
-	if _, ok := r.rules[from]; !ok {
-		r.rules[from] = make(map[string]int)
+	return from, to, nil
+}
+
+// conversion is the conversion from -> to with priority.
+type conversion struct {
+	from, to pattern
+	priority int
+}
+
+func (c *conversion) match(from, to types.Type) bool {
+	return c.from.match(from) && c.to.match(to)
+}
+
+// mustParsePattern is like parsePattern but panics if s is invalid.
+func mustParsePattern(s string) pattern {
+	p, err := parsePattern(s)
+	if err != nil {
+		panic("typeconv: " + err.Error())
 	}
-	r.rules[from][to] = r.next
+	return p
+}
+
+// Context represents the kind of code where a type conversion happens.
+type Context string
+
+const (
+	ContextVarDecl      Context = "vardecl"      // var x T = v
+	ContextFuncArg      Context = "funcarg"      // f(v)
+	ContextAssign       Context = "assign"       // x = v
+	ContextBinary       Context = "binary"       // x + y
+	ContextReturn       Context = "return"       // return v
+	ContextCompositeLit Context = "compositelit" // T{v}
+	ContextSend         Context = "send"         // ch <- v
+	ContextShift        Context = "shift"        // x << n
+	ContextComparison   Context = "comparison"   // x == y
+	ContextUnary        Context = "unary"        // -x
+	ContextIndex        Context = "index"        // s[i]
+)
+
+// Contexts holds all the contexts.
+var Contexts = []Context{
+	ContextVarDecl,
+	ContextFuncArg,
+	ContextAssign,
+	ContextBinary,
+	ContextReturn,
+	ContextCompositeLit,
+	ContextSend,
+	ContextShift,
+	ContextComparison,
+	ContextUnary,
+	ContextIndex,
+}
+
+// template is the conversion template of conversion from -> to.
+type template struct {
+	from, to pattern
+	tmpl     *Template
+}
+
+// policy allows or denies the conversion from -> to in contexts.
+type policy struct {
+	from, to pattern
+	ctxs     map[Context]bool // nil means any context
+	allow    bool
+}
+
+func (p *policy) match(ctx Context, from, to types.Type) bool {
+	return p.from.match(from) && p.to.match(to) && (p.ctxs == nil || p.ctxs[ctx])
+}
+
+// Add adds type conversion rule. Earlier rules have higher priority. It
+// panics if the type patterns are invalid (see ParseConversion).
+func (r *Rule) Add(from, to string) {
+	r.rules = append(r.rules, conversion{
+		from:     mustParsePattern(from),
+		to:       mustParsePattern(to),
+		priority: r.next,
+	})
 	r.next--
 }
 
 // ConvertibleTo reports whether a "from" type is convertible to a "to" type.
-func (r *Rule) ConvertibleTo(from, to string) (priority int, ok bool) {
-	if r.rules == nil {
-		return 0, false
+// The priority of the first rule which matches the types is returned.
+func (r *Rule) ConvertibleTo(from, to types.Type) (priority int, ok bool) {
+	for i := range r.rules {
+		if c := &r.rules[i]; c.match(from, to) {
+			return c.priority, true
+		}
+	}
+	return 0, false
+}
+
+// Allow allows the conversion from "from" to "to" in ctxs, or in any context
+// if ctxs is empty. Conversions are allowed by default except in binary
+// expressions, where the conversion must be added by Add or allowed
+// explicitly. A later policy takes precedence over earlier ones. It panics if
+// the type patterns are invalid.
+func (r *Rule) Allow(from, to string, ctxs ...Context) {
+	r.addPolicy(from, to, ctxs, true)
+}
+
+// Deny denies the conversion from "from" to "to" in ctxs, or in any context
+// if ctxs is empty.
+func (r *Rule) Deny(from, to string, ctxs ...Context) {
+	r.addPolicy(from, to, ctxs, false)
+}
+
+func (r *Rule) addPolicy(from, to string, ctxs []Context, allow bool) {
+	p := policy{from: mustParsePattern(from), to: mustParsePattern(to), allow: allow}
+	if len(ctxs) > 0 {
+		p.ctxs = make(map[Context]bool)
+		for _, ctx := range ctxs {
+			p.ctxs[ctx] = true
+		}
+	}
+	r.policies = append(r.policies, p)
+}
+
+// AddTemplate adds the conversion template of conversion from "from" to
+// "to". The template is used instead of type conversion T(x) in any context
+// where the conversion is allowed, so the types don't need to be convertible
+// (e.g. int -> string by strconv.Itoa({{x}})). A later template takes
+// precedence over earlier ones. It panics if the type patterns are invalid.
+func (r *Rule) AddTemplate(from, to string, tmpl *Template) {
+	r.templates = append(r.templates, template{
+		from: mustParsePattern(from),
+		to:   mustParsePattern(to),
+		tmpl: tmpl,
+	})
+}
+
+// Template returns the conversion template of conversion from "from" to "to"
+// in the package of import path pkgPath.
+func (r *Rule) Template(pkgPath string, from, to types.Type) (*Template, bool) {
+	for _, rule := range r.chain(pkgPath) {
+		for i := len(rule.templates) - 1; i >= 0; i-- {
+			if t := rule.templates[i]; t.from.match(from) && t.to.match(to) {
+				return t.tmpl, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Package returns the rule specific to the package of import path. It takes
+// precedence over r for the package.
+func (r *Rule) Package(path string) *Rule {
+	if r.packages == nil {
+		r.packages = make(map[string]*Rule)
+	}
+	if _, ok := r.packages[path]; !ok {
+		r.packages[path] = &Rule{}
+	}
+	return r.packages[path]
+}
+
+// Allowed reports whether the conversion from "from" to "to" in ctx of the
+// package of import path pkgPath is allowed.
+func (r *Rule) Allowed(pkgPath string, ctx Context, from, to types.Type) bool {
+	for _, rule := range r.chain(pkgPath) {
+		for i := len(rule.policies) - 1; i >= 0; i-- {
+			if p := rule.policies[i]; p.match(ctx, from, to) {
+				return p.allow
+			}
+		}
 	}
-	if _, ok := r.rules[from]; !ok {
-		return 0, false
+	if ctx == ContextBinary {
+		_, ok := r.Priority(pkgPath, from, to)
+		return ok
 	}
-	priority, ok = r.rules[from][to]
+	return true
+}
+
+// Priority returns the priority of the conversion from "from" to "to" in the
+// package of import path pkgPath. It reports false if the conversion is not
+// added by Add directly or by a chain of them (see Path).
+func (r *Rule) Priority(pkgPath string, from, to types.Type) (priority int, ok bool) {
+	_, priority, ok = r.Path(pkgPath, from, to)
 	return priority, ok
 }
 
-// DefaultRule holds default type conversion rules whose conversion are safe.
-var DefaultRule = &Rule{}
+// chain returns the rules for the package in order of precedence.
+func (r *Rule) chain(pkgPath string) []*Rule {
+	if rule, ok := r.packages[pkgPath]; ok {
+		return []*Rule{rule, r}
+	}
+	return []*Rule{r}
+}
 
-func init() {
+// NewDefaultRule returns a new rule which holds default type conversion rules
+// whose conversion are safe. Each call returns an independent rule, so rules
+// can be added without affecting others.
+func NewDefaultRule() *Rule {
+	r := &Rule{}
 	rules := []struct {
 		from string
 		to   string
@@ -67,7 +266,8 @@ func init() {
 
 		{"float32", "float64"},
 	}
-	for _, r := range rules {
-		DefaultRule.Add(r.from, r.to)
+	for _, rule := range rules {
+		r.Add(rule.from, rule.to)
 	}
+	return r
 }
@@ -0,0 +1,94 @@
+package typeconv
+
+import (
+	"go/types"
+	"testing"
+)
+
+// typ returns the type of universe scope by name.
+func typ(name string) types.Type {
+	return types.Universe.Lookup(name).Type()
+}
+
+func TestRule_Allowed(t *testing.T) {
+	r := &Rule{}
+	r.Add("int", "int64")
+	r.Deny("int", "uint")
+	r.Deny("int", "float64", ContextFuncArg, ContextReturn)
+	r.Allow("int", "float32", ContextBinary)
+	r.Package("example.com/p").Allow("int", "uint", ContextAssign)
+	r.Package("example.com/p").Deny("int", "int64", ContextBinary)
+
+	tests := []struct {
+		pkg  string
+		ctx  Context
+		from string
+		to   string
+		want bool
+	}{
+		{"", ContextVarDecl, "int", "int64", true},
+		{"", ContextBinary, "int", "int64", true},
+		{"", ContextBinary, "int64", "int", false},
+		{"", ContextBinary, "int", "float32", true},
+		{"", ContextVarDecl, "int", "uint", false},
+		{"", ContextAssign, "int", "uint", false},
+		{"", ContextFuncArg, "int", "float64", false},
+		{"", ContextReturn, "int", "float64", false},
+		{"", ContextAssign, "int", "float64", true},
+		{"example.com/p", ContextAssign, "int", "uint", true},
+		{"example.com/p", ContextVarDecl, "int", "uint", false},
+		{"example.com/p", ContextBinary, "int", "int64", false},
+		{"example.com/q", ContextBinary, "int", "int64", true},
+	}
+	for _, tt := range tests {
+		if got := r.Allowed(tt.pkg, tt.ctx, typ(tt.from), typ(tt.to)); got != tt.want {
+			t.Errorf("Allowed(%q, %s, %s, %s) == %v, want %v", tt.pkg, tt.ctx, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestRule_Allowed_precedence(t *testing.T) {
+	r := &Rule{}
+	r.Deny("int", "uint")
+	r.Allow("int", "uint", ContextAssign)
+	if !r.Allowed("", ContextAssign, typ("int"), typ("uint")) {
+		t.Error("later Allow should take precedence over Deny")
+	}
+	if r.Allowed("", ContextVarDecl, typ("int"), typ("uint")) {
+		t.Error("Deny should be applied to other contexts")
+	}
+}
+
+func TestRule_Priority_patterns(t *testing.T) {
+	r := &Rule{}
+	r.Add("int8", "int64")
+	r.Add("signed", "float64")
+	r.Add("~int", "int64")
+	r.Deny("*int*", "uint*", ContextAssign)
+
+	myInt := types.NewNamed(types.NewTypeName(0, nil, "MyInt", nil), typ("int"), nil)
+	tests := []struct {
+		from, to types.Type
+		want     int
+		ok       bool
+	}{
+		{typ("int8"), typ("int64"), 0, true},
+		{typ("int8"), typ("float64"), -1, true},
+		{typ("uint8"), typ("float64"), 0, false},
+		{typ("int"), typ("int64"), -2, true},
+		{myInt, typ("int64"), -2, true},
+		{myInt, typ("float64"), 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := r.Priority("", tt.from, tt.to)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Priority(%v, %v) == (%d, %v), want (%d, %v)", tt.from, tt.to, got, ok, tt.want, tt.ok)
+		}
+	}
+	if r.Allowed("", ContextAssign, typ("int16"), typ("uint32")) {
+		t.Error("int16 -> uint32 should be denied by *int* -> uint*")
+	}
+	if !r.Allowed("", ContextAssign, typ("float64"), typ("uint32")) {
+		t.Error("float64 -> uint32 should be allowed")
+	}
+}
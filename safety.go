@@ -0,0 +1,159 @@
+package typeconv
+
+import (
+	"fmt"
+	"go/types"
+)
+
+// Safety is the safety class of a type conversion.
+type Safety int
+
+const (
+	// Lossless conversion preserves any value (e.g. int32 -> int64).
+	Lossless Safety = iota
+	// SignChanging conversion may change the sign of the value
+	// (e.g. int -> uint, uint64 -> int64).
+	SignChanging
+	// Truncating conversion may lose high bits or precision of the value
+	// (e.g. int64 -> int32, int64 -> float64, float64 -> float32).
+	Truncating
+	// FloatToInt conversion discards the fractional part of the value and
+	// its result is implementation-specific if the value overflows
+	// (e.g. float64 -> int).
+	FloatToInt
+)
+
+func (s Safety) String() string {
+	switch s {
+	case Lossless:
+		return "lossless"
+	case SignChanging:
+		return "sign-changing"
+	case Truncating:
+		return "truncating"
+	case FloatToInt:
+		return "float-to-int"
+	}
+	return "unknown"
+}
+
+// LossyPolicy is the policy for lossy (not Lossless) conversions.
+type LossyPolicy int
+
+const (
+	// LossyAllow emits lossy conversions as they are.
+	LossyAllow LossyPolicy = iota
+	// LossyRefuse doesn't fix errors which require lossy conversions.
+	LossyRefuse
+	// LossyCheck wraps lossy conversions of numbers with a checked helper
+	// function which panics if the value changes by the conversion. Other
+	// lossy conversions are refused.
+	LossyCheck
+)
+
+var lossyPolicyNames = []string{
+	LossyAllow:  "allow",
+	LossyRefuse: "refuse",
+	LossyCheck:  "check",
+}
+
+func (p LossyPolicy) String() string {
+	if int(p) < len(lossyPolicyNames) {
+		return lossyPolicyNames[p]
+	}
+	return "unknown"
+}
+
+// Set sets the policy by name ("allow", "refuse" or "check"). It implements
+// flag.Value with *LossyPolicy.
+func (p *LossyPolicy) Set(name string) error {
+	for i, n := range lossyPolicyNames {
+		if n == name {
+			*p = LossyPolicy(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown lossy conversion policy: %q (allow, refuse or check)", name)
+}
+
+// Classify returns the safety class of conversion from type "from" to type
+// "to". Conversions other than numbers are considered Lossless. The size of
+// int, uint and uintptr is assumed to be 64 bits for "from" and 32 bits for
+// "to" to be portable.
+func Classify(from, to types.Type) Safety {
+	f, ok1 := from.Underlying().(*types.Basic)
+	t, ok2 := to.Underlying().(*types.Basic)
+	if !ok1 || !ok2 || f.Info()&types.IsNumeric == 0 || t.Info()&types.IsNumeric == 0 {
+		return Lossless
+	}
+	if f.Kind() == t.Kind() {
+		return Lossless
+	}
+	finfo, tinfo := f.Info(), t.Info()
+	switch {
+	case finfo&types.IsInteger != 0 && tinfo&types.IsInteger != 0:
+		fbits, tbits := basicBits(f, 64), basicBits(t, 32)
+		if isPlatformInt(f) && isPlatformInt(t) {
+			tbits = fbits
+		}
+		fsigned, tsigned := finfo&types.IsUnsigned == 0, tinfo&types.IsUnsigned == 0
+		switch {
+		case tbits < fbits:
+			return Truncating
+		case fsigned == tsigned, !fsigned && tbits > fbits:
+			return Lossless
+		}
+		return SignChanging
+	case finfo&types.IsInteger != 0 && tinfo&types.IsFloat != 0:
+		if basicBits(f, 64) > mantissaBits(t) {
+			return Truncating
+		}
+		return Lossless
+	case finfo&types.IsFloat != 0 && tinfo&types.IsInteger != 0:
+		return FloatToInt
+	case finfo&types.IsFloat != 0 && tinfo&types.IsFloat != 0,
+		finfo&types.IsComplex != 0 && tinfo&types.IsComplex != 0:
+		if basicBits(t, 32) < basicBits(f, 64) {
+			return Truncating
+		}
+		return Lossless
+	}
+	return Lossless
+}
+
+// basicBits returns the size of numeric type t in bits. intBits is used for
+// int, uint and uintptr and untyped constants.
+func basicBits(t *types.Basic, intBits int) int {
+	switch t.Kind() {
+	case types.Int8, types.Uint8:
+		return 8
+	case types.Int16, types.Uint16:
+		return 16
+	case types.Int32, types.Uint32, types.Float32:
+		return 32
+	case types.Int64, types.Uint64, types.Float64, types.Complex64:
+		return 64
+	case types.Complex128:
+		return 128
+	}
+	return intBits
+}
+
+// isPlatformInt reports whether t is int, uint or uintptr whose size
+// depends on the platform.
+func isPlatformInt(t *types.Basic) bool {
+	switch t.Kind() {
+	case types.Int, types.Uint, types.Uintptr:
+		return true
+	}
+	return false
+}
+
+// mantissaBits returns the number of bits which float type t can represent
+// integers exactly.
+func mantissaBits(t *types.Basic) int {
+	if t.Kind() == types.Float32 {
+		return 24
+	}
+	return 53
+}
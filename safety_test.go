@@ -0,0 +1,41 @@
+package typeconv
+
+import (
+	"go/types"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	basic := func(kind types.BasicKind) types.Type { return types.Typ[kind] }
+	named := types.NewNamed(types.NewTypeName(0, nil, "MyInt", nil), types.Typ[types.Int], nil)
+	tests := []struct {
+		from, to types.Type
+		want     Safety
+	}{
+		{basic(types.Int32), basic(types.Int64), Lossless},
+		{basic(types.Int32), basic(types.Int), Lossless},
+		{basic(types.Int), basic(types.Int64), Lossless},
+		{basic(types.Uint8), basic(types.Int16), Lossless},
+		{basic(types.Int16), basic(types.Float32), Lossless},
+		{basic(types.Float32), basic(types.Float64), Lossless},
+		{named, basic(types.Int), Lossless},
+		{basic(types.String), basic(types.Int), Lossless},
+		{basic(types.Int), basic(types.Uint), SignChanging},
+		{basic(types.Int8), basic(types.Uint64), SignChanging},
+		{basic(types.Uint64), basic(types.Int64), SignChanging},
+		{basic(types.Int64), basic(types.Int), Truncating},
+		{basic(types.Int64), basic(types.Int32), Truncating},
+		{basic(types.Uint64), basic(types.Uint32), Truncating},
+		{basic(types.Int), basic(types.Float64), Truncating},
+		{basic(types.Int32), basic(types.Float32), Truncating},
+		{basic(types.Float64), basic(types.Float32), Truncating},
+		{basic(types.Complex128), basic(types.Complex64), Truncating},
+		{basic(types.Float64), basic(types.Int64), FloatToInt},
+		{basic(types.Float32), basic(types.Uint8), FloatToInt},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.from, tt.to); got != tt.want {
+			t.Errorf("Classify(%v, %v) == %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
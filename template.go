@@ -0,0 +1,161 @@
+package typeconv
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Template is a conversion template which converts expression {{x}} by
+// functions or methods instead of type conversion (e.g. strconv.Itoa({{x}})
+// or {{x}}.Seconds()).
+//
+// Packages are referred by their import paths (e.g.
+// github.com/foo/bar.FromInt({{x}})) and rendered with the names of imports
+// in the file. Missing imports are added.
+type Template struct {
+	src string
+	// operand reports whether the conversion can be an operand of other
+	// expressions without parentheses.
+	operand bool
+	// prefix and suffix are the parts before and after {{x}}.
+	prefix, suffix []templatePart
+}
+
+// templatePart is a literal text or a qualified identifier of a package.
+type templatePart struct {
+	text string
+	path string // import path if it's a qualified identifier
+}
+
+var (
+	templatePlaceholder = regexp.MustCompile(`\{\{\s*x\s*\}\}`)
+	// templateRef matches string literals and qualified identifiers which
+	// don't follow selectors or operands (e.g. {{x}}.Seconds()).
+	templateRef = regexp.MustCompile("\"(?:\\\\.|[^\"\\\\])*\"|`[^`]*`|'(?:\\\\.|[^'\\\\])*'|" +
+		`(^|[^\w.)\]}])((?:[\w.\-]+/)*[A-Za-z_]\w*)\.([A-Za-z_]\w*)`)
+)
+
+// ParseTemplate parses the conversion template s which must contain {{x}}
+// exactly once.
+func ParseTemplate(s string) (*Template, error) {
+	locs := templatePlaceholder.FindAllStringIndex(s, -1)
+	if len(locs) != 1 {
+		return nil, fmt.Errorf("conversion template must contain {{x}} exactly once: %q", s)
+	}
+	t := &Template{
+		src:    s,
+		prefix: parseTemplateParts(s[:locs[0][0]]),
+		suffix: parseTemplateParts(s[locs[0][1]:]),
+	}
+	expr, err := parser.ParseExpr(t.render("x", templatePackageName))
+	if err != nil {
+		return nil, fmt.Errorf("invalid conversion template %q: %v", s, err)
+	}
+	t.operand = isOperand(expr)
+	return t, nil
+}
+
+func parseTemplateParts(s string) []templatePart {
+	var parts []templatePart
+	last := 0
+	for _, m := range templateRef.FindAllStringSubmatchIndex(s, -1) {
+		if m[4] < 0 {
+			// string literal
+			continue
+		}
+		parts = append(parts,
+			templatePart{text: s[last:m[4]]},
+			templatePart{path: s[m[4]:m[5]]},
+		)
+		last = m[5]
+	}
+	return append(parts, templatePart{text: s[last:]})
+}
+
+// String returns the source of the template.
+func (t *Template) String() string {
+	return t.src
+}
+
+// render renders the template with x. qualifier returns the name of the
+// package of import path.
+func (t *Template) render(x string, qualifier func(path string) string) string {
+	prefix, suffix := t.wrap(qualifier)
+	return prefix + x + suffix
+}
+
+// wrap returns the texts before and after {{x}}.
+func (t *Template) wrap(qualifier func(path string) string) (prefix, suffix string) {
+	return renderTemplateParts(t.prefix, qualifier), renderTemplateParts(t.suffix, qualifier)
+}
+
+func renderTemplateParts(parts []templatePart, qualifier func(path string) string) string {
+	var b strings.Builder
+	for _, p := range parts {
+		if p.path != "" {
+			b.WriteString(qualifier(p.path))
+			continue
+		}
+		b.WriteString(p.text)
+	}
+	return b.String()
+}
+
+// argument reports whether {{x}} is an argument of a call in the template,
+// so that the expression doesn't need parentheses.
+func (t *Template) argument() bool {
+	prefix, suffix := t.wrap(func(path string) string { return path })
+	prefix, suffix = strings.TrimRight(prefix, " \t"), strings.TrimLeft(suffix, " \t")
+	return (strings.HasSuffix(prefix, "(") || strings.HasSuffix(prefix, ",")) &&
+		(strings.HasPrefix(suffix, ")") || strings.HasPrefix(suffix, ","))
+}
+
+// templatePackageName guesses the name of the package of import path, which
+// is used when the package is not imported yet.
+func templatePackageName(importPath string) string {
+	name := path.Base(importPath)
+	if isMajorVersion(name) && path.Dir(importPath) != "." {
+		name = path.Base(path.Dir(importPath))
+	}
+	// gopkg.in/yaml.v3
+	if i := strings.LastIndex(name, "."); i > 0 && isMajorVersion(name[i+1:]) {
+		name = name[:i]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// isMajorVersion reports whether the last element of import path is major
+// version suffix (e.g. v2).
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	for _, r := range s[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isOperand reports whether x can be an operand of selectors, index
+// expressions and calls without parentheses.
+func isOperand(x ast.Expr) bool {
+	switch x.(type) {
+	case *ast.Ident, *ast.BasicLit, *ast.CompositeLit, *ast.ParenExpr,
+		*ast.SelectorExpr, *ast.IndexExpr, *ast.IndexListExpr, *ast.SliceExpr,
+		*ast.TypeAssertExpr, *ast.CallExpr:
+		return true
+	}
+	return false
+}
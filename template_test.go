@@ -0,0 +1,63 @@
+package typeconv
+
+import "testing"
+
+func TestParseTemplate(t *testing.T) {
+	qualifier := func(path string) string {
+		return map[string]string{"strconv": "strconv", "github.com/foo/go-bar/v2": "bar2"}[path]
+	}
+	tests := []struct {
+		tmpl     string
+		want     string
+		operand  bool
+		argument bool
+	}{
+		{"strconv.Itoa({{x}})", "strconv.Itoa(v)", true, true},
+		{"{{x}}.Seconds()", "v.Seconds()", true, false},
+		{"{{ x }}.Seconds()", "v.Seconds()", true, false},
+		{"github.com/foo/go-bar/v2.FromInt({{x}}, 10)", "bar2.FromInt(v, 10)", true, true},
+		{"float64({{x}}) / 1e9", "float64(v) / 1e9", false, true},
+		{`strconv.Quote("a.b") + {{x}}.String()`, `strconv.Quote("a.b") + v.String()`, false, false},
+		{"-{{x}}", "-v", false, false},
+	}
+	for _, tt := range tests {
+		tmpl, err := ParseTemplate(tt.tmpl)
+		if err != nil {
+			t.Errorf("ParseTemplate(%q): %v", tt.tmpl, err)
+			continue
+		}
+		if got := tmpl.render("v", qualifier); got != tt.want {
+			t.Errorf("ParseTemplate(%q).render() == %q, want %q", tt.tmpl, got, tt.want)
+		}
+		if tmpl.operand != tt.operand {
+			t.Errorf("ParseTemplate(%q).operand == %v, want %v", tt.tmpl, tmpl.operand, tt.operand)
+		}
+		if got := tmpl.argument(); got != tt.argument {
+			t.Errorf("ParseTemplate(%q).argument() == %v, want %v", tt.tmpl, got, tt.argument)
+		}
+	}
+}
+
+func TestParseTemplate_invalid(t *testing.T) {
+	for _, s := range []string{"strconv.Itoa(x)", "f({{x}}, {{x}})", "strconv.Itoa({{x}}", "{{x}} +"} {
+		if _, err := ParseTemplate(s); err == nil {
+			t.Errorf("ParseTemplate(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestTemplatePackageName(t *testing.T) {
+	for path, want := range map[string]string{
+		"strconv":                 "strconv",
+		"encoding/json":           "json",
+		"github.com/foo/go-bar":   "bar",
+		"github.com/foo/bar/v2":   "bar",
+		"gopkg.in/yaml.v3":        "yaml",
+		"example.com/foo-bar/baz": "baz",
+		"example.com/foo-bar":     "foo_bar",
+	} {
+		if got := templatePackageName(path); got != want {
+			t.Errorf("templatePackageName(%q) == %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package testdata
+
+import (
+	"net/http"
+	"time"
+)
+
+func f(x int) {
+	http.DefaultClient.Timeout = time.Duration(x)
+}
@@ -0,0 +1,7 @@
+package testdata
+
+import "net/http"
+
+func f(x int) {
+	http.DefaultClient.Timeout = x
+}
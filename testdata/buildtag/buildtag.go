@@ -0,0 +1,8 @@
+//go:build gotypeconv
+
+package buildtag
+
+func f() {
+	var x int = 1
+	var _ int64 = x
+}
@@ -0,0 +1,9 @@
+package chain
+
+type MyInt int32
+
+func f(i8 int8, m MyInt, u uint8, f32 float32) {
+	_ = i8 + m
+	_ = u + m
+	_ = f32 + m
+}
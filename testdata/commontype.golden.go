@@ -0,0 +1,20 @@
+package main
+
+func main() {
+	var (
+		i8  int8  = 1
+		i16 int16 = 2
+	)
+	var f float64 = float64(i8) + float64(i16)
+	var r float64 = int16(i8) % i16
+	_ = int16(i8) + i16
+	_ = int16(i8) < i16
+	takeFloat32(float32(i8) * float32(i16))
+	_ = []int64{int64(i8) - int64(i16)}
+}
+
+func takeFloat32(f float32) {}
+
+func sum(a int8, b int16) int64 {
+	return (int64(a) + int64(b))
+}
@@ -0,0 +1,20 @@
+package main
+
+func main() {
+	var (
+		i8  int8  = 1
+		i16 int16 = 2
+	)
+	var f float64 = i8 + i16
+	var r float64 = i8 % i16
+	_ = i8 + i16
+	_ = i8 < i16
+	takeFloat32(i8 * i16)
+	_ = []int64{i8 - i16}
+}
+
+func takeFloat32(f float32) {}
+
+func sum(a int8, b int16) int64 {
+	return (a + b)
+}
@@ -0,0 +1,13 @@
+package testdata
+
+type MyInt int
+
+type Name string
+
+func comparison(a MyInt, n int, name Name, s string) bool {
+	_ = a == MyInt(n)
+	_ = MyInt(n) < a
+	_ = a != a
+	_ = name == Name(s+"!")
+	return MyInt(n+1) >= a
+}
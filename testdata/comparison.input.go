@@ -0,0 +1,13 @@
+package testdata
+
+type MyInt int
+
+type Name string
+
+func comparison(a MyInt, n int, name Name, s string) bool {
+	_ = a == n
+	_ = n < a
+	_ = a != int(a)
+	_ = name == s+"!"
+	return (n + 1) >= a
+}
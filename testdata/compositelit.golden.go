@@ -0,0 +1,16 @@
+package main
+
+type Point struct {
+	X, Y float64
+}
+
+func main() {
+	x, y := 1, 2
+	_ = Point{X: float64(x), Y: float64(y)}
+	_ = Point{float64(x), float64(y)}
+	_ = []*Point{{float64(x), 0}}
+	_ = []int64{int64(x), int64(y)}
+	_ = [2]int64{1: int64(y)}
+	_ = map[int64]float64{int64(x): float64(y), 0: float64(y)}
+	_ = map[string]uint{"x": uint(x), "y": uint(y)}
+}
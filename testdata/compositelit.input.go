@@ -0,0 +1,16 @@
+package main
+
+type Point struct {
+	X, Y float64
+}
+
+func main() {
+	x, y := 1, 2
+	_ = Point{X: x, Y: float64(y)}
+	_ = Point{x, y}
+	_ = []*Point{{x, 0}}
+	_ = []int64{x, y}
+	_ = [2]int64{1: y}
+	_ = map[int64]float64{int64(x): float64(y), 0: y}
+	_ = map[string]uint{"x": uint(x), "y": y}
+}
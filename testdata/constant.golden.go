@@ -0,0 +1,20 @@
+package main
+
+type Celsius float64
+
+const limit int = 100
+
+func main() {
+	var a uint = 1
+	var b uint = 2
+	var c Celsius = 36.5
+	var d uint8 = uint8(limit)
+	var e float32 = 1099511627776
+	var f uint = int(-1)
+	var g int64 = 0x7f
+	var h uint = -1
+	var i int8 = 300
+	var x int64
+	_ = x + 2
+	_, _, _, _, _, _, _, _, _ = a, b, c, d, e, f, g, h, i
+}
@@ -0,0 +1,20 @@
+package main
+
+type Celsius float64
+
+const limit int = 100
+
+func main() {
+	var a uint = int(1)
+	var b uint = int(1) + int(1)
+	var c Celsius = float64(36.5)
+	var d uint8 = limit
+	var e float32 = int64(1 << 40)
+	var f uint = int(-1)
+	var g int64 = int32(0x7f)
+	var h uint = -1
+	var i int8 = 300
+	var x int64
+	_ = x + int(2)
+	_, _, _, _, _, _, _, _, _ = a, b, c, d, e, f, g, h, i
+}
@@ -0,0 +1,9 @@
+package fixpoint
+
+func f() {
+	var a int = 1
+	var b int64 = 2
+	var c int32 = 3
+	var _ float64 = a + b + c
+	var _ int = "string"
+}
@@ -4,7 +4,7 @@ func f() {
 	x := 1
 	funcarg(float64(x))
 	funcarg(float64(x + 1))
-	funcarg(float64(int(1) + 1))
+	funcarg(2)
 	funcarg2(int64(x))
 }
 
@@ -0,0 +1,8 @@
+package goversion
+
+func f() {
+	var a int = 1
+	var b int64 = 2
+	// The shift count is signed after a + b is fixed, which requires go1.13.
+	_ = 1 << (a + b)
+}
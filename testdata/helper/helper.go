@@ -0,0 +1,28 @@
+package helper
+
+type Celsius float64
+
+func sum(xs []float64) float64 {
+	var s float64
+	for _, x := range xs {
+		s += x
+	}
+	return s
+}
+
+func main() {
+	xs := []int{1, 2, 3}
+	_ = sum(xs)
+	var ys []int64 = xs
+	scores := map[string]int32{"a": 1}
+	var m map[string]float64
+	m = scores
+	n := 1
+	var p *int64 = &n
+	q := &n
+	var r *float64 = q
+	temps := []Celsius{1, 2}
+	_ = sum(temps)
+	var us []uint = xs
+	_, _, _, _, _ = ys, m, p, r, us
+}
@@ -0,0 +1,30 @@
+package testdata
+
+import "io"
+
+type Buffer struct{ b []byte }
+
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.b = append(b.b, p...)
+	return len(p), nil
+}
+
+type holder struct{ buf Buffer }
+
+func write(w io.Writer) {}
+
+func newBuffer() Buffer { return Buffer{} }
+
+func iface(buf Buffer, bufs []Buffer, h *holder, m map[string]Buffer) io.Writer {
+	write(buf)
+	var w io.Writer = bufs[0]
+	w = h.buf
+	write((buf))
+	write(Buffer{})
+	ch := make(chan io.Writer, 1)
+	ch <- buf
+	_ = []io.Writer{buf, w}
+	write(m["a"])
+	write(newBuffer())
+	return buf
+}
@@ -0,0 +1,19 @@
+package testdata
+
+func index(s []int, arr [4]int, m map[int64]string, str string, f float64, i32 int32, u uint64) {
+	_ = s[int(f)]
+	_ = arr[int(f)]
+	_ = (&arr)[int(f/2)]
+	_ = str[int(f)]
+	_ = s[1:int(f)]
+	_ = s[1:2:int(f)]
+	_ = str[u:int(f)]
+	_ = m[int64(i32)]
+	_ = m[int64(u)]
+	_ = make([]int, int(f))
+	_ = make([]int, 0, int(f))
+	_ = make(map[int]int, int(f))
+	_ = s[1]
+	_ = s[1.5]
+	_ = s[u]
+}
@@ -0,0 +1,19 @@
+package testdata
+
+func index(s []int, arr [4]int, m map[int64]string, str string, f float64, i32 int32, u uint64) {
+	_ = s[f]
+	_ = arr[(f)]
+	_ = (&arr)[f/2]
+	_ = str[f]
+	_ = s[1:f]
+	_ = s[1:2:f]
+	_ = str[u:f]
+	_ = m[i32]
+	_ = m[u]
+	_ = make([]int, f)
+	_ = make([]int, 0, f)
+	_ = make(map[int]int, f)
+	_ = s[float64(1)]
+	_ = s[1.5]
+	_ = s[u]
+}
@@ -0,0 +1,12 @@
+package main
+
+func main() {
+	var f float64 = 1.5
+	var i int = 1
+	var i32 int32 = 1
+	var u uint = uint(i)
+	var n int = int(f)
+	var m int32 = int32(i)
+	var x int64 = int64(i32)
+	_, _, _, _ = u, n, m, x
+}
@@ -0,0 +1,80 @@
+package main
+
+import "strconv"
+
+func atoi(s string) (int, error) {
+	return strconv.Atoi(s)
+}
+
+func add(x, y int64) int64 {
+	return x + y
+}
+
+func pair() (int, int) {
+	return 1, 2
+}
+
+func parse(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, err := atoi(s)
+	return int64(v), err
+}
+
+func main() {
+	v := int64(1)
+	v2, v3 := pair()
+	_ = add(int64(v2), int64(v3))
+	v4, v5 := pair()
+	var a, b int64 = int64(v4), int64(v5)
+	v6, v7 := pair()
+	a, _ = int64(v6), v7
+	n, err := parse("1")
+	v8, err2 := atoi("1")
+	n, err = int64(v8), err2
+	_, _, _, _ = v, a, b, n
+	_ = err
+}
+
+func order() {
+	// The call of pair() cannot be moved before add(1, 2).
+	_ = [2]int64{add(1, 2), add(pair())}
+}
+
+func check(x int64, err error) bool {
+	return err == nil
+}
+
+func conditional(a bool, n int) {
+	// The calls are evaluated conditionally or repeatedly, so they cannot
+	// be moved before the statements.
+	if a {
+	} else if check(atoi("1")) {
+	}
+	for i := 0; i < n && check(atoi("1")); i++ {
+	}
+	for i := 0; i < n; i, _ = i+1, check(atoi("1")) {
+	}
+	_ = a || check(atoi("1"))
+	switch check(atoi("1")) {
+	}
+	switch {
+	case check(atoi("1")):
+	}
+	ch := make(chan bool)
+	select {
+	case ch <- check(atoi("2")):
+	default:
+	}
+}
+
+func sameBlock() {
+	v, err := atoi("1")
+	if ok := check(int64(v), err); ok {
+	}
+	v2, err2 := atoi("2")
+	_ = check(int64(v2), err2)
+	v3, err3 := atoi("3")
+	_ = check(int64(v3), err3)
+}
@@ -0,0 +1,72 @@
+package main
+
+import "strconv"
+
+func atoi(s string) (int, error) {
+	return strconv.Atoi(s)
+}
+
+func add(x, y int64) int64 {
+	return x + y
+}
+
+func pair() (int, int) {
+	return 1, 2
+}
+
+func parse(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return atoi(s)
+}
+
+func main() {
+	v := int64(1)
+	_ = add(pair())
+	var a, b int64 = pair()
+	a, _ = pair()
+	n, err := parse("1")
+	n, err = atoi("1")
+	_, _, _, _ = v, a, b, n
+	_ = err
+}
+
+func order() {
+	// The call of pair() cannot be moved before add(1, 2).
+	_ = [2]int64{add(1, 2), add(pair())}
+}
+
+func check(x int64, err error) bool {
+	return err == nil
+}
+
+func conditional(a bool, n int) {
+	// The calls are evaluated conditionally or repeatedly, so they cannot
+	// be moved before the statements.
+	if a {
+	} else if check(atoi("1")) {
+	}
+	for i := 0; i < n && check(atoi("1")); i++ {
+	}
+	for i := 0; i < n; i, _ = i+1, check(atoi("1")) {
+	}
+	_ = a || check(atoi("1"))
+	switch check(atoi("1")) {
+	}
+	switch {
+	case check(atoi("1")):
+	}
+	ch := make(chan bool)
+	select {
+	case ch <- check(atoi("2")):
+	default:
+	}
+}
+
+func sameBlock() {
+	if ok := check(atoi("1")); ok {
+	}
+	_ = check(atoi("2"))
+	_ = check(atoi("3"))
+}
@@ -0,0 +1,8 @@
+package pattern
+
+type ID int
+
+func f(id ID, n int, i8 int8, u uint) {
+	_ = id + n
+	_ = i8 + u
+}
@@ -0,0 +1,15 @@
+package testdata
+
+import (
+	tm "time"
+)
+
+type MyInt int
+
+func f(x int, p *MyInt) {
+	var _ tm.Duration = x
+	var _ MyInt = x
+	var _ *int = p
+	// cannot convert
+	var _ map[string]MyInt = map[string]int{}
+}
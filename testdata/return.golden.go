@@ -4,3 +4,29 @@ func returnfn() (float64, int64) {
 	var x, y int = 1, 4
 	return float64(x), int64(y)
 }
+
+func nested(x int) int64 {
+	if x > 0 {
+		for i := 0; i < x; i++ {
+			return int64(i)
+		}
+	}
+	return int64(x)
+}
+
+func closure(x int) {
+	f := func() (float64, error) {
+		return float64(x), nil
+	}
+	_ = f
+}
+
+func grouped(x, y int) (a, b int64) {
+	return int64(x), int64(y)
+}
+
+type T struct{ n int }
+
+func (t *T) Value() uint {
+	return uint(t.n)
+}
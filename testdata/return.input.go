@@ -4,3 +4,29 @@ func returnfn() (float64, int64) {
 	var x, y int = 1, 4
 	return x, y
 }
+
+func nested(x int) int64 {
+	if x > 0 {
+		for i := 0; i < x; i++ {
+			return i
+		}
+	}
+	return x
+}
+
+func closure(x int) {
+	f := func() (float64, error) {
+		return x, nil
+	}
+	_ = f
+}
+
+func grouped(x, y int) (a, b int64) {
+	return x, y
+}
+
+type T struct{ n int }
+
+func (t *T) Value() uint {
+	return t.n
+}
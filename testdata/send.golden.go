@@ -0,0 +1,12 @@
+package main
+
+type Duration int64
+
+func main() {
+	x := 1
+	ch := make(chan int64, 3)
+	ch <- int64(x)
+	ch <- int64(x)
+	done := make(chan<- Duration, 1)
+	done <- Duration(x + 1)
+}
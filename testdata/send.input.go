@@ -0,0 +1,12 @@
+package main
+
+type Duration int64
+
+func main() {
+	x := 1
+	ch := make(chan int64, 3)
+	ch <- x
+	ch <- int(int64(x))
+	done := make(chan<- Duration, 1)
+	done <- x + 1
+}
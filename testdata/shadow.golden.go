@@ -0,0 +1,14 @@
+package testdata
+
+import (
+	"net/http"
+	time2 "time"
+)
+
+func f(time int) {
+	http.DefaultClient.Timeout = time2.Duration(time)
+}
+
+func g(x int) {
+	http.DefaultClient.Timeout = time2.Duration(x)
+}
@@ -0,0 +1,11 @@
+package testdata
+
+import "net/http"
+
+func f(time int) {
+	http.DefaultClient.Timeout = time
+}
+
+func g(x int) {
+	http.DefaultClient.Timeout = x
+}
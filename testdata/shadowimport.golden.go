@@ -0,0 +1,17 @@
+package testdata
+
+import (
+	"net/http"
+	"time"
+	time2 "time"
+)
+
+func f(x int) {
+	http.DefaultClient.Timeout = time.Duration(x)
+	time := x
+	http.DefaultClient.Timeout = time2.Duration(time)
+}
+
+func g() time.Duration {
+	return time.Second
+}
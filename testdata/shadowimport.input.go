@@ -0,0 +1,16 @@
+package testdata
+
+import (
+	"net/http"
+	"time"
+)
+
+func f(x int) {
+	http.DefaultClient.Timeout = x
+	time := x
+	http.DefaultClient.Timeout = time
+}
+
+func g() time.Duration {
+	return time.Second
+}
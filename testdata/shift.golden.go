@@ -0,0 +1,13 @@
+package testdata
+
+func shift(n int, f float64) {
+	var x int = 1
+	_ = int(f) << 2
+	_ = x << uint(f)
+	_ = x >> uint(f*2)
+	var y uint64 = uint64(f) << n
+	_ = x << 2
+	_ = x << -1
+	_ = 1.5 << n
+	_ = y
+}
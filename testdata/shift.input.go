@@ -0,0 +1,13 @@
+package testdata
+
+func shift(n int, f float64) {
+	var x int = 1
+	_ = f << 2
+	_ = x << f
+	_ = x >> (f * 2)
+	var y uint64 = f << n
+	_ = float64(x) << 2
+	_ = x << -1
+	_ = 1.5 << n
+	_ = y
+}
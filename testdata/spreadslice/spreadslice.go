@@ -0,0 +1,41 @@
+package spreadslice
+
+func max(x int64, ys ...int64) int64 {
+	for _, y := range ys {
+		if y > x {
+			x = y
+		}
+	}
+	return x
+}
+
+func bs() []int {
+	return []int{1, 2}
+}
+
+func main() {
+	a, ys := 1, []int{2, 3}
+	v := max(int64(a), ys...)
+	if v > 0 {
+		_ = max(v, bs()...)
+	}
+	_ = append([]int64{v}, ys...)
+}
+
+func conditional(a bool, n int, ys []int) {
+	if a {
+	} else if max(1, ys...) > 0 {
+	}
+	for i := 0; i < n && max(1, ys...) > 0; i++ {
+	}
+	_ = a && max(1, ys...) > 0
+	switch {
+	case max(1, ys...) > 0:
+	}
+}
+
+func sameBlock(ys []int) {
+	if v := max(1, ys...); v > 0 {
+	}
+	_ = max(2, ys...)
+}
@@ -0,0 +1,34 @@
+package template
+
+import (
+	"fmt"
+	"time"
+)
+
+func seconds(d time.Duration) float64 {
+	return d
+}
+
+func label(n int) string {
+	var s string = n
+	return s
+}
+
+func ratio(d, total time.Duration) float64 {
+	var r float64 = d + total
+	return r
+}
+
+func bytes(s string, ch chan []byte) {
+	ch <- s
+}
+
+func scale(d time.Duration, k float64) float64 {
+	return d * k
+}
+
+func printAll(ns []int) {
+	for _, n := range ns {
+		fmt.Println(label(n))
+	}
+}
@@ -0,0 +1,10 @@
+package testdata
+
+func unary(u uint, u32 uint32) {
+	const c uint = 1
+	_ = -c
+	var i64 int64 = -u32
+	var i8 int8 = -uint8(3)
+	var x int = -u
+	_, _, _ = i64, i8, x
+}
@@ -0,0 +1,18 @@
+package main
+
+func max(x int64, ys ...int64) int64 {
+	for _, y := range ys {
+		if y > x {
+			x = y
+		}
+	}
+	return x
+}
+
+func main() {
+	a, b, c := 1, 2, 3
+	_ = max(int64(a), int64(b), int64(c))
+	bs := []int{b, c}
+	// Spread slices are converted only if Options.SpreadSlice is enabled.
+	_ = max(int64(a), bs...)
+}
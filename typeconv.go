@@ -1,320 +1,794 @@
 // Package typeconv provides missing implicit type conversion in Go by
-// rewriting AST.
+// rewriting source code.
 package typeconv
 
 import (
+	"bytes"
 	"fmt"
 	"go/ast"
 	"go/parser"
+	"go/token"
 	"go/types"
+	"io/ioutil"
+	"strings"
 
-	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
 )
 
-// Load creates the initial packages specified by conf along with slice of
-// types.Error.
-func Load(conf loader.Config, args []string) (*loader.Program, []types.Error, error) {
-	conf.AllowErrors = true
-	conf.ParserMode = parser.ParseComments
+// LoadMode is the packages.LoadMode required by Load.
+const LoadMode = packages.NeedName | packages.NeedFiles | packages.NeedImports |
+	packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo | packages.NeedTypesSizes |
+	packages.NeedModule
+
+// Program holds the initial packages loaded by Load.
+type Program struct {
+	Fset     *token.FileSet
+	Packages []*packages.Package
+
+	overlay map[string][]byte
+	// ownOverlay reports whether overlay is not shared with packages.Config.
+	ownOverlay bool
+}
+
+// Load loads the packages specified by patterns (e.g. "./...", import paths or
+// .go files) using go/packages along with slice of types.Error found in them.
+//
+// cfg may be nil. Build tags can be passed by cfg.BuildFlags (e.g.
+// []string{"-tags=foo"}) and GOFLAGS in the environment are respected as well
+// as module mode.
+func Load(cfg *packages.Config, patterns ...string) (*Program, []types.Error, error) {
+	conf := &packages.Config{}
+	if cfg != nil {
+		*conf = *cfg
+	}
+	conf.Mode |= LoadMode
+	if conf.Fset == nil {
+		conf.Fset = token.NewFileSet()
+	}
+	pkgs, err := packages.Load(conf, patterns...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load packages: %v", err)
+	}
 	var typeErrs []types.Error
-	typeErrFn := conf.TypeChecker.Error
-	conf.TypeChecker.Error = func(err error) {
-		if err, ok := err.(types.Error); ok {
-			typeErrs = append(typeErrs, err)
-		}
-		if typeErrFn != nil {
-			typeErrFn(err)
+	for _, pkg := range pkgs {
+		// Ignore list errors as long as the package is parsed because `go list`
+		// also reports compile errors of packages with type errors.
+		for _, e := range pkg.Errors {
+			if e.Kind == packages.ParseError || len(pkg.Syntax) == 0 {
+				return nil, nil, fmt.Errorf("failed to load package %s: %v", pkg.ID, e)
+			}
 		}
+		typeErrs = append(typeErrs, pkg.TypeErrors...)
 	}
-	if _, err := conf.FromArgs(args, true); err != nil {
-		return nil, nil, err
-	}
-	conf.TypeCheckFuncBodies = func(path string) bool {
-		// TODO(haya14busa): skip check in func bodies for dependent packages.
-		_, ok := stdlibs[path]
-		if ok {
-			return false // do not check std lib
+	return &Program{Fset: conf.Fset, Packages: pkgs, overlay: conf.Overlay}, typeErrs, nil
+}
+
+// PathEnclosingInterval returns the package and ast.Node that contain source
+// interval [start, end), and all the node's ancestors up to the AST root.
+// It reports whether the interval exactly matches a node as well.
+func (prog *Program) PathEnclosingInterval(start, end token.Pos) (*packages.Package, []ast.Node, bool) {
+	for _, pkg := range prog.Packages {
+		for _, f := range pkg.Syntax {
+			if f.FileStart <= start && end <= f.FileEnd {
+				path, exact := astutil.PathEnclosingInterval(f, start, end)
+				return pkg, path, exact
+			}
 		}
-		return true
 	}
-	prog, err := conf.Load()
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to load program: %v", err)
+	return nil, nil, false
+}
+
+// ReadFile returns the content of the file. It respects the overlay of
+// packages.Config passed to Load.
+func (prog *Program) ReadFile(filename string) ([]byte, error) {
+	if src, ok := prog.overlay[filename]; ok {
+		return src, nil
 	}
-	return prog, typeErrs, nil
+	return ioutil.ReadFile(filename)
+}
+
+// RewriteProgam rewrites program AST to fix type conversion errors with the
+// default rule and options. See Rewriter.RewriteProgam.
+func RewriteProgam(prog *Program, typeErrs []types.Error) error {
+	return (&Rewriter{}).RewriteProgam(prog, typeErrs)
 }
 
 // RewriteProgam rewrites program AST to fix type conversion errors.
-func RewriteProgam(prog *loader.Program, typeErrs []types.Error) error {
-	// Collects AST rewrite functions and run them in the end instead of running
-	// them for each time because rewriting AST affects getting next node
-	// positions by prog.PathEnclosingInterval.
-	var rewrites []func()
-	for _, e := range typeErrs {
-		pkg, path, exact := prog.PathEnclosingInterval(e.Pos, e.Pos)
-		if !exact {
-			return fmt.Errorf("cannot get exact node position for type error: %v", e)
-		}
-
-		terr := NewTypeErr(e)
-		if terr == nil {
-			continue
+//
+// It applies all fixes returned by Fixes to the source files and replaces the
+// files of prog.Packages with the rewritten ones. The rewritten sources are
+// returned by prog.ReadFile. Note that type information of the packages is
+// not updated. Use RewriteProgamFixpoint to re-typecheck them, or Fixes and
+// ApplyFixes to preview or select fixes.
+func (r *Rewriter) RewriteProgam(prog *Program, typeErrs []types.Error) error {
+	fixes, err := r.Fixes(prog, typeErrs)
+	if err != nil {
+		return err
+	}
+	_, err = prog.applyFixes(fixes)
+	return err
+}
+
+// FixpointResult is the result of RewriteProgamFixpoint.
+type FixpointResult struct {
+	// Passes is the number of passes which rewrote the program.
+	Passes int
+	// Remaining holds the type errors which remain after the last pass.
+	Remaining []types.Error
+	// Unfixable holds the fixes of Remaining which describe why they cannot
+	// be fixed (see Fix.Unfixable).
+	Unfixable []*Fix
+}
+
+// RewriteProgamFixpoint rewrites program with the default rule and options
+// repeatedly. See Rewriter.RewriteProgamFixpoint.
+func RewriteProgamFixpoint(prog *Program, typeErrs []types.Error, maxPasses int) (*FixpointResult, error) {
+	return (&Rewriter{}).RewriteProgamFixpoint(prog, typeErrs, maxPasses)
+}
+
+// RewriteProgamFixpoint rewrites program like RewriteProgam repeatedly.
+//
+// Fixing an error often reveals the next one, so after each pass it
+// re-typechecks the rewritten packages and fixes the type errors found in
+// them again until no fixable errors remain or maxPasses passes are done.
+// Type information of the rewritten packages is updated.
+func (r *Rewriter) RewriteProgamFixpoint(prog *Program, typeErrs []types.Error, maxPasses int) (*FixpointResult, error) {
+	res := &FixpointResult{}
+	// fixes are the fixes of typeErrs unless they are nil.
+	var fixes []*Fix
+	for res.Passes < maxPasses {
+		var err error
+		fixes, err = r.Fixes(prog, typeErrs)
+		if err != nil {
+			return nil, err
+		}
+		if len(fixes) == 0 {
+			break
+		}
+		changed, err := prog.applyFixes(fixes)
+		if err != nil {
+			return nil, err
+		}
+		if len(changed) == 0 {
+			break
+		}
+		res.Passes++
+		r.logf("pass %d: %d fixes applied", res.Passes, len(fixes))
+		fixes = nil
+		for _, pkg := range changed {
+			prog.check(pkg)
+		}
+		typeErrs = typeErrs[:0:0]
+		for _, pkg := range prog.Packages {
+			typeErrs = append(typeErrs, pkg.TypeErrors...)
+		}
+	}
+	res.Remaining = typeErrs
+	if fixes == nil && len(typeErrs) > 0 {
+		var err error
+		if fixes, err = r.Fixes(prog, typeErrs); err != nil {
+			return nil, err
 		}
+	}
+	for _, fix := range fixes {
+		if fix.Unfixable != "" {
+			res.Unfixable = append(res.Unfixable, fix)
+		}
+	}
+	return res, nil
+}
 
-		var rewrite func()
-		switch terr := terr.(type) {
-		case *ErrVarDecl:
-			rewrite = rewriteErrVarDecl(path, pkg, terr)
-		case *ErrFuncArg:
-			rewrite = rewriteErrFuncArg(path, pkg, terr)
-		case *ErrAssign:
-			rewrite = rewriteErrAssign(path, pkg, terr)
-		case *ErrMismatched:
-			rewrite = rewriteErrMismatched(path, pkg, terr)
-		case *ErrReturn:
-			rewrite = rewriteErrReturn(path, pkg, terr)
+// applyFixes applies fixes to the source files of prog and replaces the files
+// of prog.Packages with the rewritten ones. It returns the packages whose
+// files are changed.
+func (prog *Program) applyFixes(fixes []*Fix) ([]*packages.Package, error) {
+	fileFixes := make(map[string][]*Fix)
+	for _, fix := range fixes {
+		fileFixes[fix.Filename] = append(fileFixes[fix.Filename], fix)
+	}
+	var changed []*packages.Package
+	for _, pkg := range prog.Packages {
+		pkgChanged := false
+		for _, f := range pkg.Syntax {
+			filename := prog.Fset.File(f.Pos()).Name()
+			fixes, ok := fileFixes[filename]
+			if !ok {
+				continue
+			}
+			src, err := prog.ReadFile(filename)
+			if err != nil {
+				return nil, err
+			}
+			res, err := ApplyFixes(prog.Fset, src, fixes)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", filename, err)
+			}
+			if bytes.Equal(src, res) {
+				continue
+			}
+			if err := prog.setSyntax(pkg, filename, res); err != nil {
+				return nil, err
+			}
+			pkgChanged = true
 		}
-		if rewrite != nil {
-			rewrites = append(rewrites, rewrite)
+		filename, src, err := prog.helperFile(pkg, fixes)
+		if err != nil {
+			return nil, err
+		}
+		if src != nil {
+			if err := prog.setSyntax(pkg, filename, src); err != nil {
+				return nil, err
+			}
+			pkgChanged = true
+		}
+		if pkgChanged {
+			changed = append(changed, pkg)
 		}
 	}
-	// Executes rewrite functions in the end.
-	for _, f := range rewrites {
-		f()
+	return changed, nil
+}
+
+// setSyntax sets the content of the file of pkg and replaces the file of
+// pkg.Syntax, or adds it if pkg doesn't have it.
+func (prog *Program) setSyntax(pkg *packages.Package, filename string, src []byte) error {
+	f, err := parser.ParseFile(prog.Fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return err
 	}
+	prog.setFile(filename, src)
+	for i, old := range pkg.Syntax {
+		if prog.Fset.File(old.Pos()).Name() == filename {
+			pkg.Syntax[i] = f
+			return nil
+		}
+	}
+	pkg.Syntax = append(pkg.Syntax, f)
 	return nil
 }
 
-// unwrapTypeConversion unwraps needless type conversion.
-func unwrapTypeConversion(node ast.Node, pkg *loader.PackageInfo, gotType, wantType string) (n ast.Expr, ok bool) {
+// setFile overrides the content of the file. It doesn't modify the overlay
+// of packages.Config passed to Load.
+func (prog *Program) setFile(filename string, src []byte) {
+	if !prog.ownOverlay {
+		overlay := make(map[string][]byte, len(prog.overlay)+1)
+		for k, v := range prog.overlay {
+			overlay[k] = v
+		}
+		prog.overlay = overlay
+		prog.ownOverlay = true
+	}
+	prog.overlay[filename] = src
+}
+
+// check re-typechecks pkg from its files and updates its type information
+// and type errors. Imports are resolved to the packages loaded by Load, and
+// the language version and sizes are the same as Load uses.
+func (prog *Program) check(pkg *packages.Package) {
+	var typeErrs []types.Error
+	loaded := pkg.Types
+	conf := &types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			return importPackage(loaded, path)
+		}),
+		Error: func(err error) {
+			if terr, ok := err.(types.Error); ok {
+				typeErrs = append(typeErrs, terr)
+			}
+		},
+		Sizes: pkg.TypesSizes,
+	}
+	if pkg.Module != nil && pkg.Module.GoVersion != "" {
+		conf.GoVersion = "go" + pkg.Module.GoVersion
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+	tpkg, _ := conf.Check(pkg.PkgPath, prog.Fset, pkg.Syntax, info)
+	pkg.Types = tpkg
+	pkg.TypesInfo = info
+	pkg.TypeErrors = typeErrs
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// importPackage returns the package of path imported by pkg. Packages which
+// pkg doesn't import directly (e.g. imports added by fixes) are looked up in
+// its dependencies so that their types are identical to the loaded ones.
+func importPackage(pkg *types.Package, path string) (*types.Package, error) {
+	seen := make(map[*types.Package]bool)
+	queue := pkg.Imports()
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if p.Path() == path {
+			return p, nil
+		}
+		queue = append(queue, p.Imports()...)
+	}
+	return nil, fmt.Errorf("package %q is not loaded", path)
+}
+
+// unwrappableConversion returns the conversion call node to gotType whose
+// operand is already wantType, which is needless.
+func unwrappableConversion(node ast.Node, info *types.Info, gotType, wantType types.Type) (*ast.CallExpr, bool) {
 	call, ok := node.(*ast.CallExpr)
 	if !ok || len(call.Args) != 1 {
 		return nil, false
 	}
-	funcName, ok := call.Fun.(*ast.Ident)
-	if !ok || funcName.Name != gotType {
+	if tv, ok := info.Types[call.Fun]; !ok || !tv.IsType() || !types.Identical(tv.Type, gotType) {
 		return nil, false
 	}
-	arg := call.Args[0]
-	innterType := pkg.TypeOf(arg)
-	if innterType.String() != wantType {
+	innerType := info.TypeOf(call.Args[0])
+	if innerType == nil || !types.Identical(innerType, wantType) {
 		return nil, false
 	}
-	return arg, true
+	return call, true
 }
 
-func rewriteErrVarDecl(path []ast.Node, pkg *loader.PackageInfo, terr *ErrVarDecl) (rewrite func()) {
-	for i := range path {
-		if i+1 >= len(path) {
-			break
-		}
-		child, parent := path[i], path[i+1]
-		if valuespec, ok := parent.(*ast.ValueSpec); ok {
-			if ok := checkConvertibleErrVarDecl(terr, valuespec, child, pkg.Info); !ok {
-				continue
-			}
-			idx := -1
-			for i, value := range valuespec.Values {
-				if value == child {
-					idx = i
-					break
-				}
-			}
-			if idx == -1 {
-				return nil
-			}
-			return func() {
-				if node, ok := unwrapTypeConversion(valuespec.Values[idx], pkg, terr.ValueType, terr.NameType); ok {
-					valuespec.Values[idx] = node
-					return
-				}
-				valuespec.Values[idx] = &ast.CallExpr{
-					Fun:  ast.NewIdent(terr.NameType),
-					Args: []ast.Expr{valuespec.Values[idx]},
-				}
-			}
-		}
+func rewriteErrVarDecl(c *fileCtx, terr *ErrVarDecl) *Fix {
+	if ok := checkErrVarDecl(terr, c.info); !ok {
+		return nil
 	}
-	return nil
+	if call, ok := unwrappableConversion(terr.Value, c.info, terr.ValueType, terr.NameType); ok {
+		return c.unwrapFix(terr, call, terr.ValueType, terr.NameType, false)
+	}
+	return c.convertFix(terr, terr.Value, terr.ValueType, terr.NameType)
 }
 
-// checkConvertibleErrVarDecl checks child type is convertible to parent type.
-// In fact, type error message seemes already covers this check... but leave it
-// for just in case.
-// e.g. `cannot convert "string" (untyped string constant) to int`
-func checkConvertibleErrVarDecl(terr *ErrVarDecl, parent *ast.ValueSpec, child ast.Node, typeinfo types.Info) bool {
-	parentExpr, ok := parent.Type.(ast.Expr)
-	if !ok {
+// checkErrVarDecl checks the types of terr match the declaration.
+func checkErrVarDecl(terr *ErrVarDecl, typeinfo *types.Info) bool {
+	parentType := typeinfo.TypeOf(terr.Spec.Type)
+	if parentType == nil || !types.Identical(parentType, terr.NameType) {
 		return false
 	}
-	parentType := typeinfo.Types[parentExpr].Type
-	if parentType.String() != terr.NameType {
+	childType := typeinfo.TypeOf(terr.Value)
+	if childType == nil || !types.Identical(childType, terr.ValueType) {
 		return false
 	}
-	childExpr, ok := child.(ast.Expr)
+	return true
+}
+
+func rewriteErrFuncArg(c *fileCtx, terr *ErrFuncArg) *Fix {
+	if call, ok := unwrappableConversion(terr.Arg, c.info, terr.ArgType, terr.ParamType); ok {
+		return c.unwrapFix(terr, call, terr.ArgType, terr.ParamType, false)
+	}
+	if terr.Call.Ellipsis.IsValid() && exprIndex(terr.Call.Args, terr.Arg) == len(terr.Call.Args)-1 {
+		if c.opts.SpreadSlice {
+			return rewriteSpreadSlice(c, terr)
+		}
+		return nil
+	}
+	return c.convertFix(terr, terr.Arg, terr.ArgType, terr.ParamType)
+}
+
+// rewriteSpreadSlice converts the spread slice argument of variadic function
+// to a new slice before the statement.
+//
+//	max(x, ys...)
+//
+// is rewritten to
+//
+//	var ys2 []int64
+//	for _, v := range ys {
+//		ys2 = append(ys2, int64(v))
+//	}
+//	max(x, ys2...)
+func rewriteSpreadSlice(c *fileCtx, terr *ErrFuncArg) *Fix {
+	from, ok1 := terr.ArgType.Underlying().(*types.Slice)
+	to, ok2 := terr.ParamType.Underlying().(*types.Slice)
+	if !ok1 || !ok2 {
+		return nil
+	}
+	if !c.convertible(from.Elem(), to.Elem()) {
+		return nil
+	}
+	if terr.Stmt == nil || callsBefore(terr.Stmt, terr.Arg.Pos(), c.info) {
+		return nil
+	}
+	if c.conditional(terr.Stmt, terr.Arg) {
+		fix := c.newFix(terr, terr.Arg, "", nil)
+		if fix != nil {
+			fix.Unfixable = fmt.Sprintf("cannot convert %s before the statement since it's evaluated conditionally or repeatedly", types.ExprString(terr.Arg))
+		}
+		return fix
+	}
+	cv, ok := c.conversion(from.Elem(), to.Elem())
 	if !ok {
-		return false
+		return nil
 	}
-	childType := typeinfo.Types[childExpr].Type
-	if childType.String() != terr.ValueType {
-		return false
+	base := "xs"
+	if id, ok := terr.Arg.(*ast.Ident); ok {
+		base = id.Name
 	}
-	return types.ConvertibleTo(childType, parentType)
+	names := c.tempNames(terr.Stmt, base, "v")
+	s, v := names[0], names[1]
+	tf := c.fset.File(terr.Stmt.Pos())
+	arg := c.src[tf.Offset(terr.Arg.Pos()):tf.Offset(terr.Arg.End())]
+	indent := c.indent(terr.Stmt.Pos())
+	var b strings.Builder
+	fmt.Fprintf(&b, "var %s %s\n", s, c.typeString(terr.ParamType))
+	fmt.Fprintf(&b, "%sfor _, %s := range %s {\n", indent, v, arg)
+	fmt.Fprintf(&b, "%s\t%s = append(%s, %s)\n", indent, s, s, cv.wrap(v))
+	fmt.Fprintf(&b, "%s}\n%s", indent, indent)
+	return c.newFix(terr, terr.Stmt, c.ruleString(terr.ArgType, terr.ParamType), []Edit{
+		{Pos: terr.Stmt.Pos(), End: terr.Stmt.Pos(), NewText: b.String()},
+		{Pos: terr.Arg.Pos(), End: terr.Arg.End(), NewText: s},
+	})
 }
 
-func rewriteErrFuncArg(path []ast.Node, pkg *loader.PackageInfo, terr *ErrFuncArg) (rewrite func()) {
-	for i := range path {
-		if i+1 >= len(path) {
-			break
+func rewriteErrAssign(c *fileCtx, terr *ErrAssign) *Fix {
+	if call, ok := unwrappableConversion(terr.Right, c.info, terr.RightType, terr.LeftType); ok {
+		return c.unwrapFix(terr, call, terr.RightType, terr.LeftType, false)
+	}
+	return c.convertFix(terr, terr.Right, terr.RightType, terr.LeftType)
+}
+
+func rewriteErrCompositeLit(c *fileCtx, terr *ErrCompositeLit) *Fix {
+	if call, ok := unwrappableConversion(terr.Elem, c.info, terr.GotType, terr.WantType); ok {
+		return c.unwrapFix(terr, call, terr.GotType, terr.WantType, false)
+	}
+	return c.convertFix(terr, terr.Elem, terr.GotType, terr.WantType)
+}
+
+func rewriteErrSend(c *fileCtx, terr *ErrSend) *Fix {
+	if call, ok := unwrappableConversion(terr.Value, c.info, terr.ValueType, terr.ElemType); ok {
+		return c.unwrapFix(terr, call, terr.ValueType, terr.ElemType, false)
+	}
+	return c.convertFix(terr, terr.Value, terr.ValueType, terr.ElemType)
+}
+
+// rewriteErrMultiValue assigns the results of multi-value function call to
+// temporary variables before the statement and converts them.
+//
+//	return f()
+//
+// is rewritten to
+//
+//	v, err := f()
+//	return int64(v), err
+func rewriteErrMultiValue(c *fileCtx, terr *ErrMultiValue) *Fix {
+	// Moving the call before the statement changes the evaluation order of
+	// calls preceding it.
+	if terr.Stmt == nil || callsBefore(terr.Stmt, terr.Call.Pos(), c.info) {
+		return nil
+	}
+	if c.conditional(terr.Stmt, terr.Call) {
+		fix := c.newFix(terr, terr.Call, "", nil)
+		if fix != nil {
+			fix.Unfixable = fmt.Sprintf("cannot move %s before the statement since it's evaluated conditionally or repeatedly", types.ExprString(terr.Call))
 		}
-		child, parent := path[i], path[i+1]
-		if call, ok := parent.(*ast.CallExpr); ok {
-			idx := -1
-			for i, arg := range call.Args {
-				if arg == child {
-					idx = i
-				}
+		return fix
+	}
+	errType := types.Universe.Lookup("error").Type()
+	bases := make([]string, terr.GotTypes.Len())
+	for i := range bases {
+		bases[i] = "v"
+		if types.Identical(terr.GotTypes.At(i).Type(), errType) {
+			bases[i] = "err"
+		}
+	}
+	names := c.tempNames(terr.Stmt, bases...)
+	var results, rules []string
+	for i, name := range names {
+		got, want := terr.GotTypes.At(i).Type(), terr.WantTypes[i]
+		if want == nil || types.AssignableTo(got, want) {
+			results = append(results, name)
+			continue
+		}
+		if !c.convertible(got, want) {
+			return nil
+		}
+		cv, ok := c.conversion(got, want)
+		if !ok {
+			return nil
+		}
+		results = append(results, cv.wrap(name))
+		rules = append(rules, c.ruleString(got, want))
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	tf := c.fset.File(terr.Stmt.Pos())
+	call := c.src[tf.Offset(terr.Call.Pos()):tf.Offset(terr.Call.End())]
+	decl := fmt.Sprintf("%s := %s\n%s", strings.Join(names, ", "), call, c.indent(terr.Stmt.Pos()))
+	return c.newFix(terr, terr.Stmt, strings.Join(rules, ", "), []Edit{
+		{Pos: terr.Stmt.Pos(), End: terr.Stmt.Pos(), NewText: decl},
+		{Pos: terr.Call.Pos(), End: terr.Call.End(), NewText: strings.Join(results, ", ")},
+	})
+}
+
+// conditional reports whether x in stmt is evaluated conditionally or
+// repeatedly, or after other parts of stmt are evaluated, so that it cannot
+// be moved before stmt: x is in the condition of if or else-if statement,
+// the condition or post statement of for statement, the tag of switch
+// statement, the expressions of case clause, the communication of select
+// case or the right operand of && or ||.
+func (c *fileCtx) conditional(stmt ast.Stmt, x ast.Expr) bool {
+	path, _ := astutil.PathEnclosingInterval(c.file, x.Pos(), x.End())
+	var child ast.Node
+	for i, n := range path {
+		switch n := n.(type) {
+		case *ast.BinaryExpr:
+			if (n.Op == token.LAND || n.Op == token.LOR) && n.Y == child {
+				return true
 			}
-			if idx == -1 {
-				continue
+		case *ast.IfStmt:
+			// Else-if statements are the Else of the outer if statement.
+			if n.Cond == child || n != stmt {
+				return true
 			}
-			return func() {
-				if node, ok := unwrapTypeConversion(call.Args[idx], pkg, terr.ArgType, terr.ParamType); ok {
-					call.Args[idx] = node
-					return
-				}
-				// TODO(haya14busa): check terr.ArgType is convertible to terr.ParamType
-				call.Args[idx] = &ast.CallExpr{
-					Fun:  ast.NewIdent(terr.ParamType),
-					Args: []ast.Expr{call.Args[idx]},
+		case *ast.ForStmt:
+			if n.Cond == child || n.Post == child {
+				return true
+			}
+		case *ast.SwitchStmt:
+			if n.Tag == child {
+				return true
+			}
+		case *ast.CaseClause:
+			// Statements in the clause body are enclosing statements by
+			// themselves, so x is in the case expressions.
+			return true
+		}
+		if n == stmt {
+			// The communication of select case is enclosing statement too.
+			if i+1 < len(path) {
+				if clause, ok := path[i+1].(*ast.CommClause); ok && clause.Comm == stmt {
+					return true
 				}
 			}
+			return false
 		}
+		child = n
 	}
-	return nil
+	return false
 }
 
-func rewriteErrAssign(path []ast.Node, pkg *loader.PackageInfo, terr *ErrAssign) (rewrite func()) {
-	for i := range path {
-		if i+1 >= len(path) {
-			break
+// callsBefore reports whether stmt contains function calls or receive
+// operations which end before pos. Type conversions are not counted.
+func callsBefore(stmt ast.Stmt, pos token.Pos, info *types.Info) bool {
+	found := false
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		if found || n == nil || n.Pos() >= pos {
+			return false
 		}
-		child, parent := path[i], path[i+1]
-		if assign, ok := parent.(*ast.AssignStmt); ok {
-			idx := -1
-			for i, r := range assign.Rhs {
-				if r == child {
-					idx = i
-					break
-				}
-			}
-			if idx == -1 {
-				continue
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if tv, ok := info.Types[n.Fun]; n.End() <= pos && (!ok || !tv.IsType()) {
+				found = true
 			}
-			return func() {
-				if node, ok := unwrapTypeConversion(assign.Rhs[idx], pkg, terr.RightType, terr.LeftType); ok {
-					assign.Rhs[idx] = node
-					return
-				}
-				left, right := assign.Lhs[idx], assign.Rhs[idx]
-				if !types.ConvertibleTo(pkg.TypeOf(right), pkg.TypeOf(left)) {
-					return
-				}
-				assign.Rhs[idx] = &ast.CallExpr{
-					Fun:  ast.NewIdent(terr.LeftType),
-					Args: []ast.Expr{assign.Rhs[idx]},
-				}
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW && n.End() <= pos {
+				found = true
 			}
+		case *ast.FuncLit:
+			return false
 		}
-	}
-
-	return nil
+		return true
+	})
+	return found
 }
 
-func rewriteErrMismatched(path []ast.Node, pkg *loader.PackageInfo, terr *ErrMismatched) (rewrite func()) {
-	for i := range path {
-		if i+1 >= len(path) {
-			break
+// rewriteErrMismatched converts the operands of binary expression to a
+// common type. It's one of the operand types, the type which the context
+// expects or a type in the rule which both operands are converted to.
+//
+//	var f float64 = i8 + i16
+//
+// is rewritten to
+//
+//	var f float64 = float64(i8) + float64(i16)
+//
+// rather than float64(int16(i8) + i16) in the next pass, if the rule allows
+// int8 -> float64 and int16 -> float64.
+func rewriteErrMismatched(c *fileCtx, terr *ErrMismatched) *Fix {
+	binaryexpr := terr.Expr
+	ltyp := terr.LeftType
+	rtyp := terr.RightType
+
+	r2l, r2lOk := c.priority(rtyp, ltyp), c.allowed(rtyp, ltyp) && c.convertible(rtyp, ltyp)
+	l2r, l2rOk := c.priority(ltyp, rtyp), c.allowed(ltyp, rtyp) && c.convertible(ltyp, rtyp)
+
+	if terr.WantType != nil || (!r2lOk && !l2rOk) {
+		if t := commonType(c, terr); t != nil && !types.Identical(t, ltyp) && !types.Identical(t, rtyp) {
+			return c.mergeFixes(terr, binaryexpr,
+				c.operandFix(terr, binaryexpr.X, ltyp, t),
+				c.operandFix(terr, binaryexpr.Y, rtyp, t))
+		} else if t != nil {
+			r2lOk, l2rOk = types.Identical(t, ltyp), types.Identical(t, rtyp)
 		}
-		child, parent := path[i], path[i+1]
-		if binaryexpr, ok := parent.(*ast.BinaryExpr); ok {
-			if !(child == binaryexpr.X || child == binaryexpr.Y) {
-				continue
-			}
+	}
 
-			return func() {
-				ltyp := pkg.Info.TypeOf(binaryexpr.X)
-				rtyp := pkg.Info.TypeOf(binaryexpr.Y)
-
-				// TODO(haya14busa): DefaultRule is global variable.
-				r2l, r2lOk := DefaultRule.ConvertibleTo(rtyp.String(), ltyp.String())
-				r2lOk = r2lOk && types.ConvertibleTo(rtyp, ltyp)
-				l2r, l2rOk := DefaultRule.ConvertibleTo(ltyp.String(), rtyp.String())
-				l2rOk = l2rOk && types.ConvertibleTo(ltyp, rtyp)
-
-				switch {
-				case (r2lOk && !l2rOk) || (r2lOk && l2rOk && r2l > l2r): // right to left
-					if node, ok := unwrapTypeConversion(binaryexpr.X, pkg, terr.LeftType, terr.RightType); ok {
-						binaryexpr.X = node
-						return
-					}
-					binaryexpr.Y = &ast.CallExpr{
-						Fun:  ast.NewIdent(ltyp.String()),
-						Args: []ast.Expr{binaryexpr.Y},
-					}
-				case (!r2lOk && l2rOk) || (r2lOk && l2rOk && r2l <= l2r): // left to right
-					if node, ok := unwrapTypeConversion(binaryexpr.Y, pkg, terr.RightType, terr.LeftType); ok {
-						binaryexpr.Y = node
-						return
-					}
-					binaryexpr.X = &ast.CallExpr{
-						Fun:  ast.NewIdent(rtyp.String()),
-						Args: []ast.Expr{binaryexpr.X},
-					}
-				}
-			}
+	switch {
+	case (r2lOk && !l2rOk) || (r2lOk && l2rOk && r2l > l2r): // right to left
+		if call, ok := unwrappableConversion(binaryexpr.X, c.info, ltyp, rtyp); ok {
+			return c.unwrapFix(terr, call, ltyp, rtyp, true)
+		}
+		return c.wrapFix(terr, binaryexpr.Y, rtyp, ltyp)
+	case (!r2lOk && l2rOk) || (r2lOk && l2rOk && r2l <= l2r): // left to right
+		if call, ok := unwrappableConversion(binaryexpr.Y, c.info, rtyp, ltyp); ok {
+			return c.unwrapFix(terr, call, rtyp, ltyp, true)
 		}
+		return c.wrapFix(terr, binaryexpr.X, ltyp, rtyp)
 	}
 	return nil
 }
 
-func rewriteErrReturn(path []ast.Node, pkg *loader.PackageInfo, terr *ErrReturn) (rewrite func()) {
-	for i := range path {
-		if i+3 >= len(path) {
-			break
-		}
-		child, parent, funcDeclNode := path[i], path[i+1], path[i+3]
-		returnStmt, ok := parent.(*ast.ReturnStmt)
-		if !ok {
-			continue
+// commonType returns the best type which both operands of terr are converted
+// to, or nil if there is no such type. Candidates are the operand types, the
+// expected type and the types in the rule. They are compared by the safety of
+// conversions, the number of conversions including the one from the result
+// to the expected type, whether it's the expected type and the priorities of
+// conversions in this order. Without the expected type, the operand types
+// are preferred over others since they need fewer conversions.
+func commonType(c *fileCtx, terr *ErrMismatched) types.Type {
+	ltyp, rtyp, want := terr.LeftType, terr.RightType, terr.WantType
+	candidates := []types.Type{ltyp, rtyp}
+	if want != nil {
+		candidates = append(candidates, want)
+	}
+	candidates = append(candidates, c.rule.nodes(c.pkg.Path(), ltyp, rtyp)[2:]...)
+
+	type score struct {
+		safety   Safety
+		casts    int
+		other    bool // not the expected type
+		priority int
+	}
+	less := func(a, b score) bool {
+		switch {
+		case a.safety != b.safety:
+			return a.safety < b.safety
+		case a.casts != b.casts:
+			return a.casts < b.casts
+		case a.other != b.other:
+			return !a.other
 		}
-		funcDecl, ok := funcDeclNode.(*ast.FuncDecl)
-		if !ok {
+		return a.priority > b.priority
+	}
+	var best types.Type
+	var bestScore score
+	for _, t := range candidates {
+		if !operatorDefined(terr.Expr.Op, t) {
 			continue
 		}
-		idx := -1
-		for i, r := range returnStmt.Results {
-			if r == child {
-				idx = i
+		s := score{other: want == nil || !types.Identical(t, want)}
+		ok := true
+		for _, from := range []types.Type{ltyp, rtyp} {
+			if types.Identical(from, t) {
+				continue
+			}
+			if !c.allowed(from, t) || !c.convertible(from, t) {
+				ok = false
+				break
+			}
+			s.casts++
+			if cs := Classify(from, t); cs > s.safety {
+				s.safety = cs
+			}
+			if p, ok := c.rule.Priority(c.pkg.Path(), from, t); ok {
+				s.priority += p
 			}
 		}
-		if idx == -1 {
+		if !ok {
 			continue
 		}
-		return func() {
-			if node, ok := unwrapTypeConversion(returnStmt.Results[idx], pkg, terr.GotType, terr.WantType); ok {
-				returnStmt.Results[idx] = node
-				return
-			}
-			gotType := pkg.Info.TypeOf(returnStmt.Results[idx])
-			wantType := pkg.Info.TypeOf(funcDecl.Type.Results.List[idx].Type)
-			if types.ConvertibleTo(gotType, wantType) {
-				returnStmt.Results[idx] = &ast.CallExpr{
-					Fun:  ast.NewIdent(wantType.String()),
-					Args: []ast.Expr{returnStmt.Results[idx]},
+		if want != nil && s.other {
+			if !types.AssignableTo(t, want) {
+				if !types.ConvertibleTo(t, want) {
+					continue
 				}
+				s.casts++
 			}
+			if cs := Classify(t, want); cs > s.safety {
+				s.safety = cs
+			}
+		}
+		if best == nil || less(s, bestScore) {
+			best, bestScore = t, s
 		}
 	}
-	return nil
+	return best
+}
+
+// operatorDefined reports whether binary operator op is defined for type t.
+func operatorDefined(op token.Token, t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return isComparison(op) && (op == token.EQL || op == token.NEQ)
+	}
+	info := b.Info()
+	switch op {
+	case token.ADD:
+		return info&(types.IsNumeric|types.IsString) != 0
+	case token.SUB, token.MUL, token.QUO:
+		return info&types.IsNumeric != 0
+	case token.REM, token.AND, token.OR, token.XOR, token.AND_NOT:
+		return info&types.IsInteger != 0
+	case token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return info&types.IsOrdered != 0
+	case token.LAND, token.LOR:
+		return info&types.IsBoolean != 0
+	}
+	return true
+}
+
+// rewriteErrShiftOperand converts the shifted operand to the integer type,
+// e.g. f << 2 to int(f) << 2.
+func rewriteErrShiftOperand(c *fileCtx, terr *ErrShiftOperand) *Fix {
+	return c.operandFix(terr, terr.Operand, terr.OperandType, terr.WantType)
+}
+
+// rewriteErrShiftCount converts the shift count to uint, e.g. x << f to
+// x << uint(f). Negative or fractional constant counts are unfixable.
+func rewriteErrShiftCount(c *fileCtx, terr *ErrShiftCount) *Fix {
+	return c.operandFix(terr, terr.Count, terr.CountType, terr.WantType)
+}
+
+// rewriteErrComparison converts the operand of the underlying type to the
+// named type, e.g. a == n to a == MyInt(n), so that the comparison keeps the
+// type of the named operand.
+func rewriteErrComparison(c *fileCtx, terr *ErrComparison) *Fix {
+	if isUnderlying(terr.RightType, terr.LeftType) {
+		return c.operandFix(terr, terr.Expr.Y, terr.RightType, terr.LeftType)
+	}
+	return c.operandFix(terr, terr.Expr.X, terr.LeftType, terr.RightType)
+}
+
+// rewriteErrUnary converts the operand of negation to the signed type, e.g.
+// -c to -int(c).
+func rewriteErrUnary(c *fileCtx, terr *ErrUnary) *Fix {
+	return c.operandFix(terr, terr.Expr.X, terr.OperandType, terr.WantType)
+}
+
+// rewriteErrIndex converts the index to int or the key type of the map, e.g.
+// s[f] to s[int(f)]. Lossy conversions follow the lossy conversion policy.
+func rewriteErrIndex(c *fileCtx, terr *ErrIndex) *Fix {
+	return c.operandFix(terr, terr.Index, terr.IndexType, terr.WantType)
+}
+
+// rewriteErrInterface takes the address of the value whose pointer
+// implements the interface, e.g. g(t) to g(&t). Values which are not
+// addressable (e.g. map index expressions and function calls) are reported
+// as unfixable since copying them to variables changes which value the
+// methods modify.
+func rewriteErrInterface(c *fileCtx, terr *ErrInterface) *Fix {
+	x := terr.Value
+	ptr := types.NewPointer(terr.ValueType)
+	if !c.allowed(terr.ValueType, ptr) {
+		return nil
+	}
+	_, lit := ast.Unparen(x).(*ast.CompositeLit)
+	if tv, ok := c.info.Types[x]; !lit && (!ok || !tv.Addressable()) {
+		fix := c.newFix(terr, x, c.ruleString(terr.ValueType, ptr), nil)
+		if fix != nil {
+			fix.Unfixable = fmt.Sprintf("%s implements %s but %s is not addressable", c.typeString(ptr), c.typeString(terr.IfaceType), types.ExprString(x))
+		}
+		return fix
+	}
+	return c.newFix(terr, x, c.ruleString(terr.ValueType, ptr), []Edit{
+		{Pos: x.Pos(), End: x.Pos(), NewText: "&"},
+	})
+}
+
+// rewriteErrReturn fixes the result of return statement. terr.WantType is
+// resolved from the signature of the enclosing function, so it handles
+// function literals, methods and grouped or named results.
+func rewriteErrReturn(c *fileCtx, terr *ErrReturn) *Fix {
+	if call, ok := unwrappableConversion(terr.Result, c.info, terr.GotType, terr.WantType); ok {
+		return c.unwrapFix(terr, call, terr.GotType, terr.WantType, false)
+	}
+	return c.convertFix(terr, terr.Result, terr.GotType, terr.WantType)
 }
@@ -4,14 +4,16 @@ import (
 	"bytes"
 	"go/format"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/kylelemons/godebug/diff"
 
-	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/go/packages"
 )
 
 func TestLoad(t *testing.T) {
@@ -20,20 +22,24 @@ func TestLoad(t *testing.T) {
 		t.Fatal(err)
 	}
 	for _, file := range files {
-		prog, typeErrs, err := Load(loader.Config{}, []string{file})
+		prog, typeErrs, err := Load(nil, file)
 		if err != nil {
-			t.Error(err)
+			t.Fatal(err)
 		}
-		if got := len(prog.InitialPackages()); got != 1 {
-			t.Errorf("len(prog.InitialPackages()) == %v, want 1", got)
+		if got := len(prog.Packages); got != 1 {
+			t.Errorf("len(prog.Packages) == %v, want 1", got)
 		}
-		pkg := prog.InitialPackages()[0]
-		if got := len(pkg.Files); got != 1 {
-			t.Errorf("len(pkg.Files) == %v, want 1", got)
+		pkg := prog.Packages[0]
+		if got := len(pkg.Syntax); got != 1 {
+			t.Errorf("len(pkg.Syntax) == %v, want 1", got)
 		}
-		f := pkg.Files[0]
-		if got := prog.Fset.File(f.Pos()).Name(); got != file {
-			t.Errorf("filename: got %v, want %v", got, file)
+		f := pkg.Syntax[0]
+		want, err := filepath.Abs(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := prog.Fset.File(f.Pos()).Name(); got != want {
+			t.Errorf("filename: got %v, want %v", got, want)
 		}
 		if len(typeErrs) == 0 {
 			t.Errorf("len(typeErrs) is empty, expect errors")
@@ -41,6 +47,23 @@ func TestLoad(t *testing.T) {
 	}
 }
 
+func TestLoad_buildTags(t *testing.T) {
+	if _, _, err := Load(nil, "./testdata/buildtag"); err == nil {
+		t.Error("Load without build tags: got nil error, want error")
+	}
+	cfg := &packages.Config{BuildFlags: []string{"-tags=gotypeconv"}}
+	prog, typeErrs, err := Load(cfg, "./testdata/buildtag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(prog.Packages); got != 1 {
+		t.Errorf("len(prog.Packages) == %v, want 1", got)
+	}
+	if got := len(typeErrs); got != 1 {
+		t.Errorf("len(typeErrs) == %v, want 1", got)
+	}
+}
+
 func TestRewriteProgram(t *testing.T) {
 	files, err := filepath.Glob("testdata/*.input.go")
 	if err != nil {
@@ -50,7 +73,7 @@ func TestRewriteProgram(t *testing.T) {
 		input := fname
 		golden := strings.Replace(input, "input.go", "golden.go", 1)
 
-		prog, typeErrs, err := Load(loader.Config{}, []string{input})
+		prog, typeErrs, err := Load(nil, input)
 		if err != nil {
 			t.Fatalf("%s: %v", fname, err)
 		}
@@ -58,7 +81,7 @@ func TestRewriteProgram(t *testing.T) {
 			t.Fatalf("%s: %v", fname, err)
 		}
 
-		f := prog.InitialPackages()[0].Files[0]
+		f := prog.Packages[0].Syntax[0]
 		buf := new(bytes.Buffer)
 		if err := format.Node(buf, prog.Fset, f); err != nil {
 			t.Fatalf("%s: %v", fname, err)
@@ -78,3 +101,222 @@ func TestRewriteProgram(t *testing.T) {
 		}
 	}
 }
+
+func TestRewriteProgamFixpoint(t *testing.T) {
+	const fname = "testdata/fixpoint/fixpoint.go"
+	prog, typeErrs, err := Load(nil, fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	rw := &Rewriter{Logger: log.New(&logs, "", 0)}
+	res, err := rw.RewriteProgamFixpoint(prog, typeErrs, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"pass 1: 1 fixes applied\n", "pass 2: 1 fixes applied\n"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log %q doesn't contain %q", logs.String(), want)
+		}
+	}
+	// a + b is fixed first and then the result and c are converted to
+	// float64 which the variable declaration expects.
+	if res.Passes != 2 {
+		t.Errorf("Passes == %d, want 2", res.Passes)
+	}
+	if len(res.Remaining) != 1 {
+		t.Errorf("Remaining == %v, want 1 error", res.Remaining)
+	}
+
+	f := prog.Packages[0].Syntax[0]
+	buf := new(bytes.Buffer)
+	if err := format.Node(buf, prog.Fset, f); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile("testdata/fixpoint/fixpoint.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := diff.Diff(buf.String(), string(b)); d != "" {
+		t.Errorf("diff: (-got +want):\n%s", d)
+	}
+}
+
+func TestRewriteProgamFixpoint_maxPasses(t *testing.T) {
+	prog, typeErrs, err := Load(nil, "testdata/fixpoint/fixpoint.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := RewriteProgamFixpoint(prog, typeErrs, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Passes != 1 {
+		t.Errorf("Passes == %d, want 1", res.Passes)
+	}
+	if len(res.Remaining) != 2 {
+		t.Errorf("Remaining == %v, want 2 errors", res.Remaining)
+	}
+}
+
+func TestRewriteProgamFixpoint_goVersion(t *testing.T) {
+	prog, typeErrs, err := Load(&packages.Config{Dir: "testdata/goversion"}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := RewriteProgamFixpoint(prog, typeErrs, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The signed shift count is reported by the second pass since the module
+	// requires go1.12.
+	if res.Passes != 2 {
+		t.Errorf("Passes == %d, want 2", res.Passes)
+	}
+	if len(res.Remaining) != 0 {
+		t.Errorf("Remaining == %v, want no errors", res.Remaining)
+	}
+
+	f := prog.Packages[0].Syntax[0]
+	buf := new(bytes.Buffer)
+	if err := format.Node(buf, prog.Fset, f); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile("testdata/goversion/goversion.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := diff.Diff(buf.String(), string(b)); d != "" {
+		t.Errorf("diff: (-got +want):\n%s", d)
+	}
+}
+
+func TestRewriteProgam_spreadSlice(t *testing.T) {
+	prog, typeErrs, err := Load(nil, "testdata/spreadslice/spreadslice.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewRewriter(Options{SpreadSlice: true}).RewriteProgam(prog, typeErrs); err != nil {
+		t.Fatal(err)
+	}
+
+	f := prog.Packages[0].Syntax[0]
+	buf := new(bytes.Buffer)
+	if err := format.Node(buf, prog.Fset, f); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile("testdata/spreadslice/spreadslice.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := diff.Diff(buf.String(), string(b)); d != "" {
+		t.Errorf("diff: (-got +want):\n%s", d)
+	}
+}
+
+func TestRewriteProgam_helpers(t *testing.T) {
+	prog, typeErrs, err := Load(nil, "testdata/helper/helper.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := RewriteProgamFixpoint(prog, typeErrs, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sum(temps) and []int -> []uint are not allowed by the default rule,
+	// and pointers are not converted.
+	if len(res.Remaining) != 4 {
+		t.Errorf("Remaining == %v, want 4 errors", res.Remaining)
+	}
+	var got []string
+	for _, fix := range res.Unfixable {
+		got = append(got, fix.Unfixable)
+	}
+	want := []string{
+		"cannot convert &n from *int to *int64 since a pointer to the converted copy doesn't alias &n",
+		"cannot convert q from *int to *float64 since a pointer to the converted copy doesn't alias q",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unfixable == %q, want %q", got, want)
+	}
+	dir, err := filepath.Abs("testdata/helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		filename string
+		golden   string
+	}{
+		{"helper.go", "helper.golden"},
+		{HelperFilename, "typeconv_helpers.golden"},
+	} {
+		got, err := prog.ReadFile(filepath.Join(dir, tt.filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := ioutil.ReadFile(filepath.Join(dir, tt.golden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := diff.Diff(string(got), string(want)); d != "" {
+			t.Errorf("%s: diff: (-got +want):\n%s", tt.filename, d)
+		}
+	}
+}
+
+func TestRewriteProgam_templates(t *testing.T) {
+	prog, typeErrs, err := Load(nil, "testdata/template/template.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := NewDefaultRule()
+	rule.Add("time.Duration", "float64")
+	for _, tt := range []struct{ from, to, tmpl string }{
+		{"time.Duration", "float64", "{{x}}.Seconds()"},
+		{"int", "string", "strconv.Itoa({{x}})"},
+		{"string", "[]byte", "[]byte({{x}})"},
+	} {
+		tmpl, err := ParseTemplate(tt.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rule.AddTemplate(tt.from, tt.to, tmpl)
+	}
+	if err := (&Rewriter{Rule: rule}).RewriteProgam(prog, typeErrs); err != nil {
+		t.Fatal(err)
+	}
+	got, err := prog.ReadFile(prog.Fset.File(prog.Packages[0].Syntax[0].Pos()).Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile("testdata/template/template.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := diff.Diff(string(got), string(want)); d != "" {
+		t.Errorf("diff: (-got +want):\n%s", d)
+	}
+}
+
+func TestRewriteProgamFixpoint_unfixable(t *testing.T) {
+	prog, typeErrs, err := Load(nil, "testdata/shift.input.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := RewriteProgamFixpoint(prog, typeErrs, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Negative and fractional constants remain after the passes.
+	if len(res.Remaining) != 2 {
+		t.Errorf("Remaining == %v, want 2 errors", res.Remaining)
+	}
+	var got []string
+	for _, fix := range res.Unfixable {
+		got = append(got, fix.Unfixable)
+	}
+	want := []string{"constant -1 overflows uint", "constant 1.5 truncated to int"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unfixable == %v, want %v", got, want)
+	}
+}
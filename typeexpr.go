@@ -0,0 +1,364 @@
+package typeconv
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// fileCtx holds the information of a file to rewrite.
+type fileCtx struct {
+	fset     *token.FileSet
+	file     *ast.File
+	filename string
+	src      []byte
+	pkg      *types.Package
+	info     *types.Info
+	opts     Options
+	rule     *Rule
+
+	// added holds the names of packages which the file doesn't import but
+	// fixes require. path -> names
+	added map[string][]string
+	// pos is the position of the fix being created. Package names are
+	// resolved at pos since local declarations may shadow them.
+	pos token.Pos
+	// pending holds the imports required by the fix being created.
+	pending []Import
+	// ctx is the context of the fix being created.
+	ctx Context
+	// helpers holds the helper functions required by the fix being created.
+	helpers []string
+	// safety is the safety class of the fix being created.
+	safety Safety
+	// temps holds the names of temporary variables which fixes declare in
+	// each scope.
+	temps map[*types.Scope]map[string]bool
+}
+
+// typeString returns the string of type t which is valid in the file.
+// Types of other packages are qualified by the names of corresponding imports
+// in the file and missing imports are recorded as pending imports.
+func (c *fileCtx) typeString(t types.Type) string {
+	return types.TypeString(t, c.qualifier)
+}
+
+// convertFun returns the function part of conversion expression to type t.
+func (c *fileCtx) convertFun(t types.Type) string {
+	s := c.typeString(t)
+	x, err := parser.ParseExpr(s)
+	if err != nil {
+		return s
+	}
+	switch x.(type) {
+	case *ast.StarExpr, *ast.FuncType, *ast.ChanType:
+		// e.g. (*T)(x), (func())(x), (<-chan T)(x)
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// allowed reports whether the rule allows the conversion from type "from" to
+// type "to" in the context of the fix being created.
+func (c *fileCtx) allowed(from, to types.Type) bool {
+	return c.rule.Allowed(c.pkg.Path(), c.ctx, from, to)
+}
+
+// priority returns the priority of the conversion from type "from" to type
+// "to" in the rule. Conversions without priority have the lowest one.
+func (c *fileCtx) priority(from, to types.Type) int {
+	if p, ok := c.rule.Priority(c.pkg.Path(), from, to); ok {
+		return p
+	}
+	return math.MinInt
+}
+
+// converter wraps expressions with a conversion.
+type converter struct {
+	prefix, suffix string
+	// paren reports whether expressions other than operands need
+	// parentheses (e.g. {{x}}.Seconds()).
+	paren bool
+	// expr reports whether the conversion is not an operand, which needs
+	// parentheses as an operand (e.g. float64({{x}}) / 1e9).
+	expr bool
+}
+
+// wrap returns the conversion of identifier x which is not an operand.
+func (cv converter) wrap(x string) string {
+	return cv.prefix + x + cv.suffix
+}
+
+// edits returns the edits which convert x. operand reports whether x is an
+// operand of other expression.
+func (cv converter) edits(x ast.Expr, operand bool) []Edit {
+	prefix, suffix := cv.prefix, cv.suffix
+	if cv.paren && !isOperand(x) {
+		prefix, suffix = prefix+"(", ")"+suffix
+	}
+	if cv.expr && operand {
+		prefix, suffix = "("+prefix, suffix+")"
+	}
+	if p, ok := x.(*ast.ParenExpr); ok && strings.HasSuffix(prefix, "(") && strings.HasPrefix(suffix, ")") {
+		// T(x) rather than T((x)).
+		return []Edit{
+			{Pos: p.Lparen, End: p.Lparen + 1, NewText: prefix},
+			{Pos: p.Rparen, End: p.Rparen + 1, NewText: suffix},
+		}
+	}
+	return []Edit{
+		{Pos: x.Pos(), End: x.Pos(), NewText: prefix},
+		{Pos: x.End(), End: x.End(), NewText: suffix},
+	}
+}
+
+// convertible reports whether type "from" is convertible to type "to" by
+// type conversion or conversion template.
+func (c *fileCtx) convertible(from, to types.Type) bool {
+	_, ok := c.template(from, to)
+	return ok || types.ConvertibleTo(from, to)
+}
+
+// operand reports whether x is an operand of unary, binary or selector
+// expression.
+func (c *fileCtx) operand(x ast.Expr) bool {
+	path, _ := astutil.PathEnclosingInterval(c.file, x.Pos(), x.End())
+	if len(path) < 2 {
+		return false
+	}
+	switch p := path[1].(type) {
+	case *ast.UnaryExpr, *ast.BinaryExpr, *ast.StarExpr:
+		return true
+	case *ast.SelectorExpr:
+		return p.X == x
+	}
+	return false
+}
+
+// template returns the conversion template of the conversion from type
+// "from" to type "to" in the rule.
+func (c *fileCtx) template(from, to types.Type) (*Template, bool) {
+	return c.rule.Template(c.pkg.Path(), from, to)
+}
+
+// conversion returns the converter from type "from" to type "to" respecting
+// the conversion templates and the lossy conversion policy. It reports false
+// if the conversion is refused. Conversions by templates are considered
+// Lossless.
+func (c *fileCtx) conversion(from, to types.Type) (converter, bool) {
+	if !c.allowed(from, to) {
+		return converter{}, false
+	}
+	if tmpl, ok := c.template(from, to); ok {
+		prefix, suffix := tmpl.wrap(c.pathQualifier)
+		return converter{prefix: prefix, suffix: suffix, paren: !tmpl.argument(), expr: !tmpl.operand}, true
+	}
+	s := Classify(from, to)
+	if s > c.safety {
+		c.safety = s
+	}
+	switch {
+	case s == Lossless || c.opts.Lossy == LossyAllow:
+		return converter{prefix: c.convertFun(to) + "(", suffix: ")"}, true
+	case c.opts.Lossy == LossyCheck && isNumber(from) && isNumber(to) && c.requireHelper(helperChecked):
+		return converter{prefix: fmt.Sprintf("%s[%s](", helperChecked, c.typeString(to)), suffix: ")"}, true
+	}
+	return converter{}, false
+}
+
+// pathQualifier returns the name of the package of import path in the file
+// like qualifier. The name of a package which is not loaded is guessed from
+// the path.
+func (c *fileCtx) pathQualifier(path string) string {
+	if path == c.pkg.Path() {
+		return ""
+	}
+	p, err := importPackage(c.pkg, path)
+	if err != nil {
+		p = types.NewPackage(path, templatePackageName(path))
+	}
+	return c.qualifier(p)
+}
+
+// qualifier returns the name of package p in the file. If the file doesn't
+// import p yet or the name of the import is shadowed at the position of the
+// fix, it records the import of p as pending.
+func (c *fileCtx) qualifier(p *types.Package) string {
+	if p == c.pkg {
+		return ""
+	}
+	used := make(map[string]bool)
+	for _, spec := range c.file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := c.importName(spec, path)
+		if path == p.Path() && name == "." {
+			return ""
+		}
+		if path == p.Path() && name != "_" && c.resolves(name, path) {
+			return name
+		}
+		used[name] = true
+	}
+	var name string
+	for _, n := range c.added[p.Path()] {
+		if c.resolves(n, p.Path()) {
+			name = n
+			break
+		}
+	}
+	if name == "" {
+		for _, names := range c.added {
+			for _, n := range names {
+				used[n] = true
+			}
+		}
+		name = p.Name()
+		for i := 2; used[name] || c.pkg.Scope().Lookup(name) != nil || c.lookup(name) != nil; i++ {
+			name = fmt.Sprintf("%s%d", p.Name(), i)
+		}
+		if c.added == nil {
+			c.added = make(map[string][]string)
+		}
+		c.added[p.Path()] = append(c.added[p.Path()], name)
+	}
+	imp := Import{Path: p.Path()}
+	if name != p.Name() {
+		imp.Name = name
+	}
+	c.pending = append(c.pending, imp)
+	return name
+}
+
+// lookup returns the object which name refers to at the position of the fix
+// or nil. Imports added by fixes are not included.
+func (c *fileCtx) lookup(name string) types.Object {
+	scope := c.pkg.Scope()
+	if s := scope.Innermost(c.pos); s != nil {
+		scope = s
+	}
+	_, obj := scope.LookupParent(name, c.pos)
+	return obj
+}
+
+// resolves reports whether name refers to the package of import path at the
+// position of the fix. Names of imports added by fixes refer to the packages
+// unless local declarations shadow them.
+func (c *fileCtx) resolves(name, path string) bool {
+	switch obj := c.lookup(name).(type) {
+	case nil:
+		return true
+	case *types.PkgName:
+		return obj.Imported().Path() == path
+	}
+	return false
+}
+
+// importName returns the name of imported package by spec in the file.
+func (c *fileCtx) importName(spec *ast.ImportSpec, path string) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	if obj, ok := c.info.Implicits[spec].(*types.PkgName); ok {
+		return obj.Imported().Name()
+	}
+	for _, imp := range c.pkg.Imports() {
+		if imp.Path() == path {
+			return imp.Name()
+		}
+	}
+	return ""
+}
+
+// tempNames returns the names of temporary variables based on bases which
+// are declared just before stmt. The names don't conflict with the names
+// visible from stmt, declared in the same scope by the code or other fixes,
+// or used in stmt.
+func (c *fileCtx) tempNames(stmt ast.Stmt, bases ...string) []string {
+	scope := c.blockScope(stmt)
+	used := make(map[string]bool)
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			used[id.Name] = true
+		}
+		return true
+	})
+	if c.temps == nil {
+		c.temps = make(map[*types.Scope]map[string]bool)
+	}
+	if c.temps[scope] == nil {
+		c.temps[scope] = make(map[string]bool)
+	}
+	temps := c.temps[scope]
+	conflict := func(name string) bool {
+		if used[name] || temps[name] {
+			return true
+		}
+		if scope == nil {
+			return false
+		}
+		if scope.Lookup(name) != nil {
+			return true
+		}
+		_, obj := scope.LookupParent(name, stmt.Pos())
+		return obj != nil
+	}
+	names := make([]string, len(bases))
+	for i, base := range bases {
+		name := base
+		for n := 2; conflict(name); n++ {
+			name = fmt.Sprintf("%s%d", base, n)
+		}
+		used[name] = true
+		temps[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// blockScope returns the scope of the block or the case clause which stmt
+// belongs to. It's not the scope of stmt itself (e.g. if statement) since
+// temporary variables are declared in the block.
+func (c *fileCtx) blockScope(stmt ast.Stmt) *types.Scope {
+	path, _ := astutil.PathEnclosingInterval(c.file, stmt.Pos(), stmt.End())
+	for i, n := range path {
+		if n == stmt && i+1 < len(path) {
+			// The scope of function body is the scope of the function,
+			// which contains the position of the body.
+			return c.pkg.Scope().Innermost(path[i+1].Pos())
+		}
+	}
+	return c.pkg.Scope().Innermost(stmt.Pos())
+}
+
+// indent returns the indentation of the line at pos.
+func (c *fileCtx) indent(pos token.Pos) string {
+	tf := c.fset.File(pos)
+	start := tf.Offset(tf.LineStart(tf.Line(pos)))
+	line := c.src[start:tf.Offset(pos)]
+	return string(line[:len(line)-len(bytes.TrimLeft(line, " \t"))])
+}
+
+// ruleString returns the string representation of type conversion rule.
+func (c *fileCtx) ruleString(from, to types.Type) string {
+	path := []types.Type{from, to}
+	if p, _, ok := c.rule.Path(c.pkg.Path(), from, to); ok {
+		path = p
+	}
+	qf := types.RelativeTo(c.pkg)
+	s := make([]string, len(path))
+	for i, t := range path {
+		s[i] = types.TypeString(t, qf)
+	}
+	return strings.Join(s, " -> ")
+}